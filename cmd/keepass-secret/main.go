@@ -1,19 +1,14 @@
 package main
 
 import (
-	"fmt"
 	"keepass-secret/internal/cmd"
 	"os"
-	"strings"
 )
 
 // delegate to cmd.Run
-// stdout/stderr are passed as strings.Builder to allow unit testing
+// stdout/stderr are passed as io.Writer to allow unit testing with strings.Builder
+// and to stream the output of long running commands (serve)
 func main() {
-	stdout := strings.Builder{}
-	stderr := strings.Builder{}
-	result := cmd.Run(os.Args[1:], &stdout, &stderr)
-	fmt.Fprint(os.Stderr, stderr.String())
-	fmt.Fprint(os.Stdout, stdout.String())
+	result := cmd.Run(os.Args[1:], os.Stdout, os.Stderr)
 	os.Exit(result)
 }
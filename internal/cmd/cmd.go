@@ -2,18 +2,18 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"strings"
 
 	"github.com/tobischo/gokeepasslib/v3"
 )
 
 // main command processing
 // - parse commandline and open database
-// - read all entries into a EntryMap structure for easy access
+// - read all entries into a EntryMap structure for easy access (merging layered databases)
 // - delegate command to Cmd... structures
 // - save database if entries have been modified (and --dry-run is not set)
-func Run(args []string, stdout *strings.Builder, stderr *strings.Builder) int {
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
 	options := NewOptions()
 
 	if !options.Parse(args, stderr) {
@@ -21,51 +21,68 @@ func Run(args []string, stdout *strings.Builder, stderr *strings.Builder) int {
 	}
 
 	if options.IsQuiet() {
-		stdout = &strings.Builder{} // route stdout to dummy string
+		stdout = io.Discard // suppress all normal output (serve logs every request)
 	}
 
 	if options.GetCmd() == "init" {
-		return CmdInit(options.GetDb(), options.GetPw(), stdout, stderr)
+		return CmdInit(options.GetDb(), options.GetPw(), options.GetKeyFile(), options.IsForce(), stdout, stderr)
 	}
 
-	readFile, err := os.Open(options.GetDb())
-	if err != nil {
-		fmt.Fprintf(stderr, "%s\n", err)
-		return 1
+	if options.GetCmd() == "serve" {
+		return CmdServe(options.GetDb(), options.GetPw(), options.GetKeyFile(), options.GetListen(), options.GetToken(), options.GetTlsCert(), options.GetTlsKey(), !options.IsNoResolve(), stdout, stderr)
 	}
-	defer readFile.Close()
 
-	db := gokeepasslib.NewDatabase()
-	db.Credentials = gokeepasslib.NewPasswordCredentials(options.GetPw())
-	err = gokeepasslib.NewDecoder(readFile).Decode(db)
+	db, err := openDatabase(options.GetDb(), options.GetPw(), options.GetKeyFile())
 	if err != nil {
 		fmt.Fprintf(stderr, "%s\n", err)
 		return 1
 	}
 
-	db.UnlockProtectedEntries()
+	root := &db.Content.Root.Groups[0]
 
-	if len(db.Content.Root.Groups) < 1 {
-		fmt.Fprintf(stderr, "missing root group\n")
-		return 1
-	}
+	// commands reading the database use the merged entry map of all layers
+	var entryMap *EntryMap
+	if options.GetCmd() != "set" && options.GetCmd() != "import" {
+		dbs := []*gokeepasslib.Database{db}
+		for i := 1; i < len(options.GetDbs()); i++ {
+			layer, err := openDatabase(options.GetDbs()[i], options.GetPws()[i], options.GetKeyFiles()[i])
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %s\n", options.GetDbs()[i], err)
+				return 1
+			}
+			dbs = append(dbs, layer)
+		}
 
-	root := &db.Content.Root.Groups[0]
+		entryMap = loadEntryMap(dbs, options.GetDbs(), !options.IsNoResolve(), stderr)
+		if options.IsLayers() {
+			entryMap.ReportLayers(stderr)
+		}
+	}
 
 	modified := false
 	result := 0
 	switch options.GetCmd() {
 	case "secrets":
-		entryMap := NewEntryMap(db)
-		return CmdSecrets(entryMap, options.GetOut(), options.GetTag(), stdout, stderr) // write secrets to yaml file
+		return CmdSecrets(entryMap, options.GetOut(), options.GetTagFilter(), options.GetFormat(), options.GetNameTemplate(), options.IsStrict(), options.IsStringData(), options.GetDiffAgainst(), stdout, stderr) // write secrets to yaml file
 	case "get":
-		entryMap := NewEntryMap(db)
+		if options.GetAs() != "" {
+			return CmdGetKeystore(entryMap, options.GetPath(), options.GetAs(), options.GetOut(), stdout, stderr) // returns keystore
+		}
 		return CmdGet(entryMap, options.GetPath(), options.GetFields()[0], stdout, stderr) // returns value in stdout
 	case "set":
 		modified = CmdSet(root, options.GetPath(), options.GetFields(), stdout, stderr) // writes to existing file
 	case "export":
-		entryMap := NewEntryMap(db)
-		return CmdExport(entryMap, options.GetOut(), stdout, stderr) // export to json file
+		return CmdExport(entryMap, options.GetOut(), options.GetTagFilter(), stdout, stderr) // export to json file
+	case "list":
+		return CmdList(entryMap, options.GetTagFilter(), stdout, stderr) // list entries matching tag filter
+	case "lint":
+		return CmdLint(entryMap, options.GetDb(), options.GetTagFilter(), options.GetNameTemplate(), options.GetFormat(), stdout, stderr) // validate annotations
+	case "certs":
+		return CmdCerts(entryMap, options.GetTagFilter(), options.GetWarnDays(), options.IsAll(), options.GetFormat(), stdout, stderr) // report certificates
+	case "apply":
+		return CmdApply(entryMap, options.GetTagFilter(), options.GetNameTemplate(), options.IsStrict(), options.GetKubeconfig(), options.GetContext(), options.GetNamespace(), options.IsCreateNamespace(), options.IsPrune(), options.GetDryRun(), stdout, stderr) // apply secrets to cluster
+	case "drift":
+		return CmdDrift(entryMap, options.GetTagFilter(), options.GetNameTemplate(), options.GetKubeconfig(), options.GetContext(), options.GetNamespace(), stdout, stderr) // compare secrets with cluster
 	case "import":
 		modified, result = CmdImport(root, options.GetIn(), stdout, stderr) // import from json file
 	default:
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// apply Secrets and ConfigMaps of all marked entries to the cluster of the kubeconfig context (server-side apply)
+// resources without namespace are applied to the namespace option or the namespace of the context
+// prune deletes resources labeled as managed by keepass-secret that are no longer generated (only in applied namespaces)
+// and were applied with the same tag filter (scope label), so runs with different tags do not prune each other
+// dryRun "client" only prints the changes, "server" sends all changes with dryRun=All
+func CmdApply(entryMap *EntryMap, filter *TagFilter, nameTemplate string, strict bool, kubeconfig string, context string, namespace string, createNamespace bool, prune bool, dryRun string, stdout io.Writer, stderr io.Writer) int {
+	warnings := &warningCounter{writer: stderr, count: entryMap.GetWarnings()} // includes unresolved references
+	resources, ok := collectResources(entryMap, filter, nameTemplate, false, stdout, warnings)
+	if !ok {
+		return 1 // failure
+	}
+
+	if strict && warnings.count > 0 {
+		fmt.Fprintf(stderr, "%d warning(s) in strict mode, nothing applied\n", warnings.count)
+		return 3 // strict mode failure
+	}
+
+	client, err := NewKubeClient(kubeconfig, context)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+
+	namespaces := setDefaultNamespace(resources, namespace, client)
+	if !checkDuplicates(resources, stderr) {
+		return 1
+	}
+
+	suffix := ""
+	if dryRun != "" {
+		suffix = " (" + dryRun + " dry run)"
+	}
+
+	if createNamespace {
+		for i := 0; i < len(namespaces); i++ {
+			object := kubeObject{ApiVersion: "v1", Kind: "Namespace", Metadata: kubeObjectMeta{Name: namespaces[i]}}
+			if dryRun != "client" {
+				if err := client.Apply("/api/v1/namespaces/"+namespaces[i], object, dryRun == "server"); err != nil {
+					fmt.Fprintf(stderr, "cannot apply namespace %s: %s\n", namespaces[i], err)
+					return 1
+				}
+			}
+			fmt.Fprintf(stdout, "namespace %s applied%s\n", namespaces[i], suffix)
+		}
+	}
+
+	applied := make(map[string]bool)
+	for i := 0; i < len(resources); i++ {
+		resource := &resources[i]
+		applied[resource.kind+" "+resource.namespace+"/"+resource.name] = true
+		if dryRun != "client" {
+			path := kubeResourcePath(resource.kind, resource.namespace, resource.name)
+			if err := client.Apply(path, resource.kubeObject(filter), dryRun == "server"); err != nil {
+				fmt.Fprintf(stderr, "cannot apply %s %s/%s: %s\n", strings.ToLower(resource.kind), resource.namespace, resource.name, err)
+				return 1
+			}
+		}
+		fmt.Fprintf(stdout, "%s %s/%s applied%s\n", strings.ToLower(resource.kind), resource.namespace, resource.name, suffix)
+	}
+
+	if prune {
+		return pruneResources(client, filter, applied, namespaces, dryRun, suffix, stdout, stderr)
+	}
+
+	return 0 // success
+}
+
+// resources without namespace get the namespace option or the namespace of the context
+// returns all namespaces of the resources (starting with the default namespace)
+func setDefaultNamespace(resources []Resource, namespace string, client *KubeClient) []string {
+	if namespace == "" {
+		namespace = client.GetNamespace()
+	}
+
+	namespaces := []string{namespace}
+	for i := 0; i < len(resources); i++ {
+		if resources[i].namespace == "" {
+			resources[i].namespace = namespace
+		}
+		if !contains(namespaces, resources[i].namespace) {
+			namespaces = append(namespaces, resources[i].namespace)
+		}
+	}
+
+	return namespaces
+}
+
+// delete managed Secrets and ConfigMaps of the namespaces and the scope of the filter which have not been applied
+func pruneResources(client *KubeClient, filter *TagFilter, applied map[string]bool, namespaces []string, dryRun string, suffix string, stdout io.Writer, stderr io.Writer) int {
+	kinds := []string{"Secret", "ConfigMap"}
+	for i := 0; i < len(kinds); i++ {
+		objects, err := client.List(kinds[i], managedSelector(filter))
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot list %ss: %s\n", strings.ToLower(kinds[i]), err)
+			return 1
+		}
+
+		sort.Slice(objects, func(a, b int) bool {
+			return objects[a].Metadata.Namespace+"/"+objects[a].Metadata.Name < objects[b].Metadata.Namespace+"/"+objects[b].Metadata.Name
+		})
+
+		for j := 0; j < len(objects); j++ {
+			meta := &objects[j].Metadata
+			if applied[kinds[i]+" "+meta.Namespace+"/"+meta.Name] || !contains(namespaces, meta.Namespace) {
+				continue
+			}
+
+			if dryRun != "client" {
+				if err := client.Delete(kubeResourcePath(kinds[i], meta.Namespace, meta.Name), dryRun == "server"); err != nil {
+					fmt.Fprintf(stderr, "cannot delete %s %s/%s: %s\n", strings.ToLower(kinds[i]), meta.Namespace, meta.Name, err)
+					return 1
+				}
+			}
+			fmt.Fprintf(stdout, "%s %s/%s pruned%s\n", strings.ToLower(kinds[i]), meta.Namespace, meta.Name, suffix)
+		}
+	}
+
+	return 0 // success
+}
+
+// returns the object sent by server-side apply, labeled as managed by keepass-secret with the scope of the filter
+func (resource *Resource) kubeObject(filter *TagFilter) kubeObject {
+	labels := map[string]string{managedByLabel: fieldManager, scopeLabel: filter.Scope()}
+	object := kubeObject{
+		ApiVersion: "v1",
+		Kind:       resource.kind,
+		Metadata:   kubeObjectMeta{Name: resource.name, Namespace: resource.namespace, Labels: labels},
+		Type:       resource.secretType,
+		Immutable:  resource.immutable,
+		Data:       make(map[string]string),
+	}
+
+	for i := 0; i < len(resource.keys); i++ {
+		value := resource.data[resource.keys[i]]
+		if resource.IsSecret() {
+			object.Data[resource.keys[i]] = base64.StdEncoding.EncodeToString(value)
+		} else {
+			object.Data[resource.keys[i]] = string(value)
+		}
+	}
+
+	return object
+}
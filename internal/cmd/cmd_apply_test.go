@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fake Kubernetes API server recording all requests
+type testKubeServer struct {
+	mutex    sync.Mutex
+	requests []string              // method, path and query of all requests
+	bodies   map[string][]byte     // body of the last PATCH per path
+	secrets  []kubeObject          // returned by list request
+	objects  map[string]kubeObject // returned by get request per path
+}
+
+func (server *testKubeServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if request.Header.Get("Authorization") != "Bearer abc" {
+		writer.WriteHeader(http.StatusUnauthorized)
+		writer.Write([]byte(`{"kind":"Status","message":"Unauthorized"}`))
+		return
+	}
+
+	server.requests = append(server.requests, request.Method+" "+request.URL.Path+" "+request.URL.RawQuery)
+	switch request.Method {
+	case http.MethodPatch:
+		body, _ := io.ReadAll(request.Body)
+		server.bodies[request.URL.Path] = body
+		writer.Write(body)
+	case http.MethodGet:
+		if strings.HasPrefix(request.URL.Path, "/api/v1/namespaces/") {
+			object, ok := server.objects[request.URL.Path]
+			if !ok {
+				writer.WriteHeader(http.StatusNotFound)
+				writer.Write([]byte(`{"kind":"Status","message":"not found"}`))
+				return
+			}
+			writer.Write(marshalJson(object))
+			return
+		}
+
+		items := make([]kubeObject, 0)
+		if strings.HasSuffix(request.URL.Path, "/secrets") {
+			items = server.secrets
+		}
+		writer.Write(marshalJson(map[string]any{"items": items}))
+	default:
+		writer.Write([]byte(`{"kind":"Status","status":"Success"}`))
+	}
+}
+
+// start fake API server and write kubeconfig with token
+func testStartKubeServer(t *testing.T) (*testKubeServer, string, func()) {
+	server := &testKubeServer{requests: make([]string, 0), bodies: make(map[string][]byte), objects: make(map[string]kubeObject)}
+	httpServer := httptest.NewServer(server)
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	content := "apiVersion: v1\nkind: Config\ncurrent-context: test\n" +
+		"clusters:\n- name: test\n  cluster:\n    server: " + httpServer.URL + "\n" +
+		"contexts:\n- name: test\n  context:\n    cluster: test\n    user: test\n    namespace: dev\n" +
+		"users:\n- name: test\n  user:\n    token: abc\n"
+	os.WriteFile(kubeconfig, []byte(content), 0600)
+
+	return server, kubeconfig, httpServer.Close
+}
+
+func testApplyEntryMap() *EntryMap {
+	return testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "admin", "Password": "secret", "Notes": "secret-type=opaque\nsecret-user=UserName\nsecret-password=Password"},
+		{"path": "/app", "Title": "app", "URL": "app.example.com", "Notes": "secret-type=configmap\nsecret-namespace=prod\nsecret-host=URL"},
+	})
+}
+
+// server-side apply of each Secret/ConfigMap with managed-by label
+func TestApply(t *testing.T) {
+	server, kubeconfig, stop := testStartKubeServer(t)
+	defer stop()
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdApply(testApplyEntryMap(), nil, "", false, kubeconfig, "", "", true, false, "", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("apply failed %d %s", result, stderr.String())
+	}
+
+	expected := "secret opaque name=db fields=user,password\nconfigmap name=app fields=host\n" +
+		"namespace dev applied\nnamespace prod applied\nsecret dev/db applied\nconfigmap prod/app applied\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+
+	expected = "PATCH /api/v1/namespaces/dev fieldManager=keepass-secret&force=true," +
+		"PATCH /api/v1/namespaces/prod fieldManager=keepass-secret&force=true," +
+		"PATCH /api/v1/namespaces/dev/secrets/db fieldManager=keepass-secret&force=true," +
+		"PATCH /api/v1/namespaces/prod/configmaps/app fieldManager=keepass-secret&force=true"
+	if actual := strings.Join(server.requests, ","); actual != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+
+	object := kubeObject{}
+	json.Unmarshal(server.bodies["/api/v1/namespaces/dev/secrets/db"], &object)
+	if object.Kind != "Secret" || object.Type != "Opaque" || object.Data["password"] != "c2VjcmV0" || object.Metadata.Labels[managedByLabel] != fieldManager || object.Metadata.Labels[scopeLabel] != "all" {
+		t.Errorf("unexpected secret %s", server.bodies["/api/v1/namespaces/dev/secrets/db"])
+	}
+
+	object = kubeObject{}
+	json.Unmarshal(server.bodies["/api/v1/namespaces/prod/configmaps/app"], &object)
+	if object.Kind != "ConfigMap" || object.Data["host"] != "app.example.com" {
+		t.Errorf("unexpected ConfigMap %s", server.bodies["/api/v1/namespaces/prod/configmaps/app"])
+	}
+}
+
+// prune deletes managed secrets of the applied namespaces which no longer exist
+func TestApplyPrune(t *testing.T) {
+	server, kubeconfig, stop := testStartKubeServer(t)
+	defer stop()
+
+	server.secrets = []kubeObject{
+		{Kind: "Secret", Metadata: kubeObjectMeta{Name: "db", Namespace: "dev"}},
+		{Kind: "Secret", Metadata: kubeObjectMeta{Name: "old", Namespace: "dev"}},
+		{Kind: "Secret", Metadata: kubeObjectMeta{Name: "other", Namespace: "other"}},
+	}
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdApply(testApplyEntryMap(), nil, "", false, kubeconfig, "", "", false, true, "server", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("apply failed %d %s", result, stderr.String())
+	}
+
+	expected := "secret opaque name=db fields=user,password\nconfigmap name=app fields=host\n" +
+		"secret dev/db applied (server dry run)\nconfigmap prod/app applied (server dry run)\nsecret dev/old pruned (server dry run)\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+
+	expected = "PATCH /api/v1/namespaces/dev/secrets/db dryRun=All&fieldManager=keepass-secret&force=true," +
+		"PATCH /api/v1/namespaces/prod/configmaps/app dryRun=All&fieldManager=keepass-secret&force=true," +
+		"GET /api/v1/secrets labelSelector=app.kubernetes.io%2Fmanaged-by%3Dkeepass-secret%2Ckeepass-secret%2Fscope%3Dall," +
+		"DELETE /api/v1/namespaces/dev/secrets/old dryRun=All," +
+		"GET /api/v1/configmaps labelSelector=app.kubernetes.io%2Fmanaged-by%3Dkeepass-secret%2Ckeepass-secret%2Fscope%3Dall"
+	if actual := strings.Join(server.requests, ","); actual != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+}
+
+// prune with tag filter only selects resources applied with the same filter
+func TestApplyPruneScope(t *testing.T) {
+	server, kubeconfig, stop := testStartKubeServer(t)
+	defer stop()
+
+	dev, _ := NewTagFilter([]string{"dev"}, "both")
+	prod, _ := NewTagFilter([]string{"prod"}, "both")
+	if dev.Scope() == prod.Scope() || dev.Scope() == "all" {
+		t.Errorf("unexpected scopes %s %s", dev.Scope(), prod.Scope())
+	}
+
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "Password": "secret", "Notes": "secret-type=opaque\nsecret-password=Password\nsecret-tags=dev"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdApply(entryMap, dev, "", false, kubeconfig, "", "", false, true, "", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("apply failed %d %s", result, stderr.String())
+	}
+
+	object := kubeObject{}
+	json.Unmarshal(server.bodies["/api/v1/namespaces/dev/secrets/db"], &object)
+	if object.Metadata.Labels[scopeLabel] != dev.Scope() {
+		t.Errorf("unexpected labels %v", object.Metadata.Labels)
+	}
+
+	expected := "GET /api/v1/secrets labelSelector=app.kubernetes.io%2Fmanaged-by%3Dkeepass-secret%2Ckeepass-secret%2Fscope%3D" + dev.Scope()
+	if len(server.requests) < 2 || server.requests[1] != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %v", server.requests)
+	}
+}
+
+// client dry run only reads from the API server, namespace option replaces the context namespace
+func TestApplyClientDryRun(t *testing.T) {
+	server, kubeconfig, stop := testStartKubeServer(t)
+	defer stop()
+
+	server.secrets = []kubeObject{{Kind: "Secret", Metadata: kubeObjectMeta{Name: "old", Namespace: "test"}}}
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdApply(testApplyEntryMap(), nil, "", false, kubeconfig, "", "test", false, true, "client", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("apply failed %d %s", result, stderr.String())
+	}
+
+	expected := "secret opaque name=db fields=user,password\nconfigmap name=app fields=host\n" +
+		"secret test/db applied (client dry run)\nconfigmap prod/app applied (client dry run)\nsecret test/old pruned (client dry run)\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+
+	for i := 0; i < len(server.requests); i++ {
+		if !strings.HasPrefix(server.requests[i], "GET ") {
+			t.Errorf("unexpected request %s", server.requests[i])
+		}
+	}
+}
+
+// API errors and unknown contexts are reported
+func TestApplyErrors(t *testing.T) {
+	_, kubeconfig, stop := testStartKubeServer(t)
+	defer stop()
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdApply(testApplyEntryMap(), nil, "", false, kubeconfig, "invalid", "", false, false, "", &stdout, &stderr)
+	if result != 1 || !strings.HasPrefix(stderr.String(), "context 'invalid' not found in kubeconfig") {
+		t.Errorf("unexpected result %d %s", result, stderr.String())
+	}
+
+	content, _ := os.ReadFile(kubeconfig)
+	os.WriteFile(kubeconfig, []byte(strings.Replace(string(content), "token: abc", "token: wrong", 1)), 0600)
+
+	stderr = strings.Builder{}
+	result = CmdApply(testApplyEntryMap(), nil, "", false, kubeconfig, "", "", false, false, "", &stdout, &stderr)
+	expected := "cannot apply secret dev/db: Unauthorized\n"
+	if result != 1 || stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %d %s", result, stderr.String())
+	}
+}
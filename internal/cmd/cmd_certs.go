@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// report entry of a single certificate
+type certReport struct {
+	Path          string   `json:"path"`
+	Field         string   `json:"field"`
+	Subject       string   `json:"subject"`
+	Sans          []string `json:"sans"`
+	Issuer        string   `json:"issuer"`
+	NotAfter      string   `json:"notAfter"`
+	DaysRemaining int      `json:"daysRemaining"`
+	Status        string   `json:"status"` // ok, warn or expired
+}
+
+// report subject, SANs, issuer and expiry of all certificates
+// scans entries of type tls, with option all every field and attachment containing a PEM certificate
+// returns 2 if a certificate expires within warnDays (or is expired)
+func CmdCerts(entryMap *EntryMap, filter *TagFilter, warnDays int, all bool, format string, stdout io.Writer, stderr io.Writer) int {
+	now := time.Now()
+	reports := make([]certReport, 0)
+	paths := entryMap.GetPaths()
+	for i := 0; i < len(paths); i++ {
+		path := paths[i]
+		values, _ := entryMap.GetValues(path)
+		notes := NewNotes(values)
+		if !filter.Match(filter.EntryTags(notes, values)) {
+			continue
+		}
+
+		fields := make([]string, 0)
+		if notes.Get("type") == "tls" {
+			fields = append(fields, "UserName")
+			if ca := notes.Get("tls-ca"); ca != "" {
+				fields = append(fields, ca)
+			}
+		}
+
+		if all {
+			names := values.GetNames()
+			sort.Strings(names)
+			for j := 0; j < len(names); j++ {
+				value, _ := values.GetValue(names[j])
+				if strings.Contains(value, "-----BEGIN CERTIFICATE-----") && !contains(fields, names[j]) {
+					fields = append(fields, names[j])
+				}
+			}
+		}
+
+		for j := 0; j < len(fields); j++ {
+			value, _ := values.GetValue(fields[j])
+			reports = append(reports, createCertReports(path, fields[j], value, warnDays, now, stderr)...)
+		}
+
+		if all {
+			names := values.GetBinaries()
+			sort.Strings(names)
+			for j := 0; j < len(names); j++ {
+				value, _ := values.GetBinary(names[j])
+				if strings.Contains(string(value), "-----BEGIN CERTIFICATE-----") {
+					reports = append(reports, createCertReports(path, names[j], string(value), warnDays, now, stderr)...)
+				}
+			}
+		}
+	}
+
+	if format == "json" {
+		fmt.Fprintf(stdout, "%s\n", marshalJson(reports))
+	} else {
+		for i := 0; i < len(reports); i++ {
+			report := &reports[i]
+			fmt.Fprintf(stdout, "%-7s %s field=%s subject=%s sans=%s issuer=%s expires=%s days=%d\n",
+				report.Status, report.Path, report.Field, report.Subject, strings.Join(report.Sans, ","), report.Issuer, report.NotAfter, report.DaysRemaining)
+		}
+	}
+
+	for i := 0; i < len(reports); i++ {
+		if reports[i].Status != "ok" {
+			return 2 // certificate expires soon
+		}
+	}
+
+	return 0 // success
+}
+
+// create report entries of all certificates contained in PEM text
+func createCertReports(path string, field string, text string, warnDays int, now time.Time, stderr io.Writer) []certReport {
+	reports := make([]certReport, 0)
+	certs, err := parseCertificates(text)
+	if err != nil {
+		fmt.Fprintf(stderr, "field '%s' of entry '%s' contains invalid certificate: %s\n", field, path, err)
+		return reports
+	}
+
+	for i := 0; i < len(certs); i++ {
+		cert := certs[i]
+		days := daysRemaining(cert, now)
+		status := "ok"
+		if cert.NotAfter.Before(now) {
+			status = "expired"
+		} else if days < warnDays {
+			status = "warn"
+		}
+
+		reports = append(reports, certReport{
+			Path:          path,
+			Field:         field,
+			Subject:       cert.Subject.String(),
+			Sans:          certificateSans(cert),
+			Issuer:        cert.Issuer.String(),
+			NotAfter:      cert.NotAfter.UTC().Format(time.DateOnly),
+			DaysRemaining: days,
+			Status:        status,
+		})
+	}
+
+	return reports
+}
+
+// returns true if list contains value
+func contains(list []string, value string) bool {
+	for i := 0; i < len(list); i++ {
+		if list[i] == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// report certificates of test database
+func TestCerts(t *testing.T) {
+	db := "test/test.kdbx"
+	pw := "1234"
+
+	args := []string{"certs", "-d", db, "-p", pw}
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := Run(args, &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("certs failed, result=%d %s", result, stderr.String())
+		return
+	}
+
+	expected := "ok      /example.com field=UserName subject=CN=example.com sans= issuer=CN=example.com expires=2032-04-01 days="
+	if !strings.HasPrefix(stdout.String(), expected) {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+
+	// warn threshold after expiry date
+	args = append(args, "--warn-days", "100000")
+	result = Run(args, &strings.Builder{}, &strings.Builder{})
+	if result != 2 {
+		t.Errorf("certs must fail with result=2, result=%d", result)
+	}
+}
+
+// report expired and soon expiring certificates as JSON, scan all fields and attachments
+func TestCertsJson(t *testing.T) {
+	key, keyText := testCreateKey(t)
+	expired := testCreateCertificate("expired.example.com", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), key, t)
+	soon := testCreateCertificate("soon.example.com", time.Now().AddDate(0, 0, 5).Add(time.Hour), key, t)
+	valid := testCreateCertificate("valid.example.com", time.Now().AddDate(1, 0, 0), key, t)
+
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/tls", "Title": "tls", "UserName": soon, "Password": keyText, "Notes": "secret-type=tls"},
+		{"path": "/other", "Title": "other", "Cert": expired, "Invalid": "-----BEGIN CERTIFICATE-----\n"},
+	})
+	values, _ := entryMap.GetValues("/other")
+	values.SetBinary("valid.pem", []byte(valid))
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdCerts(entryMap, nil, 30, true, "json", &stdout, &stderr)
+	if result != 2 {
+		t.Errorf("certs must fail with result=2, result=%d", result)
+	}
+
+	reports := make([]certReport, 0)
+	if err := json.Unmarshal([]byte(stdout.String()), &reports); err != nil {
+		t.Errorf("invalid JSON %s", err)
+		return
+	}
+
+	if len(reports) != 3 {
+		t.Errorf("unexpected number of reports %d", len(reports))
+		return
+	}
+
+	if reports[0].Status != "warn" || reports[0].DaysRemaining != 5 || reports[0].Subject != "CN=soon.example.com" {
+		t.Errorf("unexpected report %v", reports[0])
+	}
+
+	if reports[1].Status != "expired" || reports[1].Field != "Cert" || reports[1].NotAfter != "2020-01-02" {
+		t.Errorf("unexpected report %v", reports[1])
+	}
+
+	if reports[2].Status != "ok" || reports[2].Field != "valid.pem" || strings.Join(reports[2].Sans, ",") != "valid.example.com,www.valid.example.com" {
+		t.Errorf("unexpected report %v", reports[2])
+	}
+
+	expected := "field 'Invalid' of entry '/other' contains invalid certificate: "
+	if !strings.HasPrefix(stderr.String(), expected) {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stderr.String())
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// compare the Secrets and ConfigMaps of the cluster with the resources generated from the database
+// changes are printed relative to the cluster (added: missing in cluster, removed: managed but not in database)
+// values are masked (see maskValue) and never printed, returns 2 if there is a drift
+func CmdDrift(entryMap *EntryMap, filter *TagFilter, nameTemplate string, kubeconfig string, context string, namespace string, stdout io.Writer, stderr io.Writer) int {
+	resources, ok := collectResources(entryMap, filter, nameTemplate, false, stdout, stderr)
+	if !ok {
+		return 1 // failure
+	}
+
+	client, err := NewKubeClient(kubeconfig, context)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+
+	namespaces := setDefaultNamespace(resources, namespace, client)
+	if !checkDuplicates(resources, stderr) {
+		return 1
+	}
+
+	live, ok := fetchLiveResources(client, filter, resources, namespaces, stderr)
+	if !ok {
+		return 1
+	}
+
+	if differences := diffResources(live, resources, stdout); differences > 0 {
+		fmt.Fprintf(stderr, "%d resource(s) drifted\n", differences)
+		return 2 // drift found
+	}
+
+	return 0 // success
+}
+
+// returns the live objects of all resources and all managed objects of the namespaces and the scope of the filter
+func fetchLiveResources(client *KubeClient, filter *TagFilter, resources []Resource, namespaces []string, stderr io.Writer) ([]Resource, bool) {
+	live := make([]Resource, 0)
+	found := make(map[string]bool)
+	for i := 0; i < len(resources); i++ {
+		resource := &resources[i]
+		object, err := client.Get(resource.kind, resource.namespace, resource.name)
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot get %s: %s\n", resource.ref(), err)
+			return nil, false
+		}
+
+		if object != nil {
+			liveResource, err := object.toResource()
+			if err != nil {
+				fmt.Fprintf(stderr, "%s\n", err)
+				return nil, false
+			}
+			live = append(live, *liveResource)
+			found[liveResource.ref()] = true
+		}
+	}
+
+	kinds := []string{"Secret", "ConfigMap"}
+	for i := 0; i < len(kinds); i++ {
+		objects, err := client.List(kinds[i], managedSelector(filter))
+		if err != nil {
+			fmt.Fprintf(stderr, "cannot list %ss: %s\n", strings.ToLower(kinds[i]), err)
+			return nil, false
+		}
+
+		for j := 0; j < len(objects); j++ {
+			objects[j].Kind = kinds[i] // items of a list do not contain the kind
+			if !contains(namespaces, objects[j].Metadata.Namespace) {
+				continue
+			}
+
+			liveResource, err := objects[j].toResource()
+			if err != nil {
+				fmt.Fprintf(stderr, "%s\n", err)
+				return nil, false
+			}
+			if !found[liveResource.ref()] {
+				live = append(live, *liveResource)
+				found[liveResource.ref()] = true
+			}
+		}
+	}
+
+	return live, true
+}
+
+// convert object returned by the API server (secret data is base64 encoded)
+func (object *kubeObject) toResource() (*Resource, error) {
+	resource := NewResource(object.Kind, object.Metadata.Name, object.Metadata.Namespace, object.Type)
+	resource.immutable = object.Immutable
+
+	keys := sortedKeys(object.Data)
+	for i := 0; i < len(keys); i++ {
+		value := []byte(object.Data[keys[i]])
+		if resource.IsSecret() {
+			decoded, err := base64.StdEncoding.DecodeString(object.Data[keys[i]])
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value of key '%s' of %s", keys[i], resource.ref())
+			}
+			value = decoded
+		}
+		resource.SetData(keys[i], value)
+	}
+
+	return resource, nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// changed keys, missing and orphaned resources are reported without values
+func TestDrift(t *testing.T) {
+	server, kubeconfig, stop := testStartKubeServer(t)
+	defer stop()
+
+	server.objects["/api/v1/namespaces/dev/secrets/db"] = kubeObject{Kind: "Secret", Type: "Opaque",
+		Metadata: kubeObjectMeta{Name: "db", Namespace: "dev"}, Data: map[string]string{"user": "YWRtaW4=", "password": "b2xk"}}
+	server.secrets = []kubeObject{
+		{Metadata: kubeObjectMeta{Name: "db", Namespace: "dev"}, Type: "Opaque", Data: map[string]string{"user": "YWRtaW4=", "password": "b2xk"}},
+		{Metadata: kubeObjectMeta{Name: "old", Namespace: "dev"}, Type: "Opaque", Data: map[string]string{"password": "b2xk"}},
+		{Metadata: kubeObjectMeta{Name: "other", Namespace: "other"}, Type: "Opaque"},
+	}
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdDrift(testApplyEntryMap(), nil, "", kubeconfig, "", "", &stdout, &stderr)
+	if result != 2 {
+		t.Errorf("drift must return 2, result=%d %s", result, stderr.String())
+	}
+
+	expected := "secret opaque name=db fields=user,password\nconfigmap name=app fields=host\n" +
+		"changed secret dev/db\n" +
+		"  changed key password (" + maskValue([]byte("old")) + " -> " + maskValue([]byte("secret")) + ")\n" +
+		"added configmap prod/app\n" +
+		"  added key host (" + maskValue([]byte("app.example.com")) + ")\n" +
+		"removed secret dev/old\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+
+	if stderr.String() != "3 resource(s) drifted\n" {
+		t.Errorf("unexpected stderr %s", stderr.String())
+	}
+
+	// no drift after all resources are up to date
+	server.objects["/api/v1/namespaces/dev/secrets/db"] = kubeObject{Kind: "Secret", Type: "Opaque",
+		Metadata: kubeObjectMeta{Name: "db", Namespace: "dev"}, Data: map[string]string{"user": "YWRtaW4=", "password": "c2VjcmV0"}}
+	server.objects["/api/v1/namespaces/prod/configmaps/app"] = kubeObject{Kind: "ConfigMap",
+		Metadata: kubeObjectMeta{Name: "app", Namespace: "prod"}, Data: map[string]string{"host": "app.example.com"}}
+	server.secrets = nil
+
+	stdout = strings.Builder{}
+	stderr = strings.Builder{}
+	if result = CmdDrift(testApplyEntryMap(), nil, "", kubeconfig, "", "", &stdout, &stderr); result != 0 {
+		t.Errorf("unexpected drift %d %s %s", result, stdout.String(), stderr.String())
+	}
+}
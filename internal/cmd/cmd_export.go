@@ -6,8 +6,8 @@ import (
 	"strings"
 )
 
-// export complete database as JSON
-func CmdExport(entryMap *EntryMap, out string, stdout io.Writer, stderr io.Writer) int {
+// export complete database (or entries matching the tag filter) as JSON
+func CmdExport(entryMap *EntryMap, out string, filter *TagFilter, stdout io.Writer, stderr io.Writer) int {
 	list := make([]map[string]string, 0)
 	paths := entryMap.GetPaths()
 	for i := 0; i < len(paths); i++ {
@@ -17,7 +17,15 @@ func CmdExport(entryMap *EntryMap, out string, stdout io.Writer, stderr io.Write
 		entry["path"] = path
 
 		values, ok := entryMap.GetValues(path)
+		if ok && !filter.Match(filter.EntryTags(NewNotes(values), values)) {
+			continue
+		}
+
 		if ok {
+			if tags := values.GetTags(); len(tags) > 0 {
+				entry["Tags"] = strings.Join(tags, ";")
+			}
+
 			names := values.GetNames()
 			for j := 0; j < len(names); j++ {
 				name := names[j]
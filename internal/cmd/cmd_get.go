@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -31,3 +32,36 @@ func CmdGet(entryMap *EntryMap, path string, field string, stdout io.Writer, std
 
 	return 0 // success
 }
+
+// convert PEM certificate and key of entry to keystore (pkcs12 or jks)
+// and write it to the output file (or stdout if no file is specified)
+func CmdGetKeystore(entryMap *EntryMap, path string, format string, out string, stdout io.Writer, stderr io.Writer) int {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // add missing "/"
+	}
+
+	values, ok := entryMap.GetValues(path)
+	if !ok {
+		fmt.Fprintf(stderr, "path '%s' does not exist\n", path)
+		return 1 // failure
+	}
+
+	title, _ := values.GetValue("Title")
+	keystore, err := createKeystore(format, title, NewNotes(values), values)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot create %s keystore of entry '%s': %s\n", format, path, err)
+		return 1 // failure
+	}
+
+	if out == "" {
+		stdout.Write(keystore) // binary output to stdout
+		return 0
+	}
+
+	if err := os.WriteFile(out, keystore, 0600); err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1 // failure
+	}
+
+	return 0 // success
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -12,10 +13,24 @@ import (
 	w "github.com/tobischo/gokeepasslib/v3/wrappers"
 )
 
-// creates new KeePass database file
-func CmdInit(db string, pw string, stdout io.Writer, stderr io.Writer) int {
-	file, err := os.Create(db)
+// creates new KeePass database file, an existing file is only overwritten with force
+func CmdInit(db string, pw string, keyFile string, force bool, stdout io.Writer, stderr io.Writer) int {
+	credentials, err := newCredentials(pw, keyFile)
 	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+
+	flags := os.O_RDWR | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(db, flags, 0666)
+	if errors.Is(err, os.ErrExist) {
+		fmt.Fprintf(stderr, "file %s already exists, use --force to overwrite it\n", db)
+		return 1
+	} else if err != nil {
 		fmt.Fprintf(stderr, "cannot create file %s\n", err)
 		return 1
 	}
@@ -44,7 +59,7 @@ func CmdInit(db string, pw string, stdout io.Writer, stderr io.Writer) int {
 	// now create the database containing the root group
 	database := &gokeepasslib.Database{
 		Header:      gokeepasslib.NewHeader(),
-		Credentials: gokeepasslib.NewPasswordCredentials(pw),
+		Credentials: credentials,
 		Content:     content,
 	}
 
@@ -27,6 +27,16 @@ func TestCreate(t *testing.T) {
 		return
 	}
 
+	// existing database is only overwritten with --force
+	stderr = strings.Builder{}
+	if result = Run(args, &stdout, &stderr); result != 1 || stderr.String() != "file init_test.kdbx already exists, use --force to overwrite it\n" {
+		t.Errorf("init of existing file must fail, result=%d %s", result, stderr.String())
+	}
+
+	if result = Run(append(args, "--force"), &stdout, &stderr); result != 0 {
+		t.Errorf("init with --force failed, result=%d", result)
+	}
+
 	// remove file
 	if err := os.Remove(db); err != nil {
 		t.Errorf("cannot delete %s", db)
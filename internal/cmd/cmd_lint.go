@@ -0,0 +1,377 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// descriptions of all lint rules (used in SARIF output)
+var lintRules = map[string]string{
+	"invalid-syntax":      "annotation cannot be parsed",
+	"duplicate-key":       "annotation is defined more than once",
+	"ignored-annotations": "annotations without secret-type are ignored",
+	"ignored-key":         "key mapping is ignored by the secret type",
+	"unknown-type":        "unknown secret-type",
+	"invalid-option":      "unknown value of an annotation",
+	"invalid-name":        "name is not a valid Kubernetes resource name",
+	"invalid-namespace":   "namespace is not a valid Kubernetes namespace",
+	"invalid-key":         "key is not a valid Secret/ConfigMap key",
+	"missing-field":       "referenced field does not exist",
+	"unresolved-value":    "value of key cannot be resolved",
+	"empty-value":         "value of key is empty",
+	"unused-override":     "namespace-scoped annotation has no effect",
+}
+
+var secretTypes = []string{"opaque", "docker", "tls", "configmap"}
+var boolAnnotations = []string{"string-data", "immutable", "name-hash"}
+var entryAnnotations = []string{"type", "tags", "namespace", "name", "string-data", "immutable", "name-hash"} // cannot be overridden per namespace
+var dockerFormats = []string{"", "dockerconfigjson", "dockercfg"}
+
+var dataKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+var namePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// single finding of the lint command
+type lintFinding struct {
+	Path     string `json:"path"`
+	Severity string `json:"severity"` // error or warning
+	Rule     string `json:"rule"`     // key of lintRules
+	Message  string `json:"message"`
+}
+
+// collects the findings of all entries
+type linter struct {
+	findings []lintFinding
+}
+
+func (linter *linter) add(path string, severity string, rule string, format string, args ...any) {
+	linter.findings = append(linter.findings, lintFinding{Path: path, Severity: severity, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate annotations of all entries matching the tag filter
+// findings are written to stdout as text, JSON or SARIF
+// returns 1 if at least one error has been found
+func CmdLint(entryMap *EntryMap, db string, filter *TagFilter, nameTemplate string, format string, stdout io.Writer, stderr io.Writer) int {
+	tmpl, err := parseNameTemplate(nameTemplate)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid name template: %s\n", err)
+		return 1
+	}
+
+	linter := &linter{findings: make([]lintFinding, 0)}
+	paths := entryMap.GetPaths()
+	for i := 0; i < len(paths); i++ {
+		values, _ := entryMap.GetValues(paths[i])
+		notes := NewNotes(values)
+		tags := filter.EntryTags(notes, values)
+		if filter.Match(tags) {
+			linter.lintEntry(paths[i], notes, values, tags, tmpl)
+		}
+	}
+
+	switch format {
+	case "json":
+		fmt.Fprintf(stdout, "%s\n", marshalJson(linter.findings))
+	case "sarif":
+		fmt.Fprintf(stdout, "%s\n", marshalJson(createSarifLog(db, linter.findings)))
+	default:
+		for i := 0; i < len(linter.findings); i++ {
+			finding := &linter.findings[i]
+			fmt.Fprintf(stdout, "%-7s %s %s [%s]\n", finding.Severity, finding.Path, finding.Message, finding.Rule)
+		}
+	}
+
+	for i := 0; i < len(linter.findings); i++ {
+		if linter.findings[i].Severity == "error" {
+			return 1 // failure
+		}
+	}
+
+	return 0 // success
+}
+
+// validate annotations of a single entry
+func (linter *linter) lintEntry(path string, notes *Notes, values Entry, tags []string, tmpl *template.Template) {
+	errors := notes.GetErrors()
+	for i := 0; i < len(errors); i++ {
+		linter.add(path, "error", "invalid-syntax", "invalid annotation in %s", errors[i])
+	}
+
+	duplicates := notes.GetDuplicates()
+	for i := 0; i < len(duplicates); i++ {
+		linter.add(path, "error", "duplicate-key", "annotation '%s' is defined more than once", duplicates[i])
+	}
+
+	secretType := notes.Get("type")
+	if secretType == "" && len(notes.GetConfigKeys()) == 0 {
+		if len(notes.keys) > 0 {
+			linter.add(path, "warning", "ignored-annotations", "annotations are ignored without secret-type")
+		}
+		return
+	}
+
+	if secretType != "" && !contains(secretTypes, secretType) {
+		linter.add(path, "error", "unknown-type", "unknown secret-type '%s'", secretType)
+		return
+	}
+
+	name, err := resolveName(path, notes, values, tags, tmpl)
+	if err != nil {
+		linter.add(path, "error", "invalid-name", "cannot apply name template: %s", err)
+	} else {
+		if name == "" {
+			name, _ = values.GetValue("Title")
+		}
+		if !isValidName(name) {
+			linter.add(path, "error", "invalid-name", "invalid name '%s'", name)
+		}
+	}
+
+	if namespaces := notes.Get("namespace"); namespaces != "" {
+		list := strings.Split(namespaces, ",")
+		for i := 0; i < len(list); i++ {
+			if len(list[i]) > 63 || !namespacePattern.MatchString(list[i]) {
+				linter.add(path, "error", "invalid-namespace", "invalid namespace '%s'", list[i])
+			}
+		}
+	}
+
+	for i := 0; i < len(boolAnnotations); i++ {
+		if text := notes.Get(boolAnnotations[i]); text != "" {
+			if _, err := strconv.ParseBool(text); err != nil {
+				linter.add(path, "error", "invalid-option", "invalid value '%s' of secret-%s", text, boolAnnotations[i])
+			}
+		}
+	}
+
+	keys := notes.GetKeys()
+	switch secretType {
+	case "opaque", "configmap":
+		for i := 0; i < len(keys); i++ {
+			linter.lintKey(path, keys[i], notes.Get(keys[i]), values)
+		}
+	case "tls":
+		linter.lintIgnoredKeys(path, secretType, keys)
+		linter.lintTls(path, notes, values)
+	case "docker":
+		linter.lintIgnoredKeys(path, secretType, keys)
+		linter.lintDocker(path, notes, values)
+	}
+
+	configKeys := notes.GetConfigKeys()
+	for i := 0; i < len(configKeys); i++ {
+		linter.lintKey(path, configKeys[i], notes.GetConfig(configKeys[i]), values)
+	}
+
+	linter.lintOverrides(path, secretType, notes, values)
+}
+
+// namespace-scoped annotations must refer to a namespace of the entry, their mappings are validated like other keys
+func (linter *linter) lintOverrides(path string, secretType string, notes *Notes, values Entry) {
+	namespaces := strings.Split(notes.Get("namespace"), ",")
+	for i := 0; i < len(notes.keys); i++ {
+		namespace, key := splitScopedKey(notes.keys[i])
+		if namespace == "" {
+			continue
+		}
+
+		if !contains(namespaces, namespace) {
+			linter.add(path, "warning", "unused-override", "annotation 'secret-%s' is ignored, namespace '%s' is not in secret-namespace", notes.keys[i], namespace)
+		} else if contains(entryAnnotations, key) {
+			linter.add(path, "warning", "unused-override", "annotation 'secret-%s' is ignored, secret-%s cannot be set per namespace", notes.keys[i], key)
+		} else if !reservedKeys[key] && (secretType == "opaque" || secretType == "configmap") {
+			linter.lintKey(path, key, notes.Get(notes.keys[i]), values)
+		}
+	}
+
+	for i := 0; i < len(notes.configKeys); i++ {
+		namespace, key := splitScopedKey(notes.configKeys[i])
+		if namespace == "" {
+			continue
+		}
+
+		if !contains(namespaces, namespace) {
+			linter.add(path, "warning", "unused-override", "annotation 'config-%s' is ignored, namespace '%s' is not in secret-namespace", notes.configKeys[i], namespace)
+		} else {
+			linter.lintKey(path, key, notes.GetConfig(notes.configKeys[i]), values)
+		}
+	}
+}
+
+// validate key name and mapping of a Secret/ConfigMap key
+func (linter *linter) lintKey(path string, key string, mapping string, values Entry) {
+	key = strings.TrimPrefix(key, ":")
+	if len(key) > 253 || !dataKeyPattern.MatchString(key) {
+		linter.add(path, "error", "invalid-key", "invalid key name '%s'", key)
+	}
+
+	if mapping == "" {
+		linter.add(path, "error", "empty-value", "empty mapping of key '%s'", key)
+		return
+	}
+
+	value, err := resolveValue(key, mapping, values)
+	if err != nil {
+		linter.add(path, "error", "unresolved-value", "%s", err)
+	} else if len(value) == 0 {
+		linter.add(path, "warning", "empty-value", "value of key '%s' is empty", key)
+	}
+}
+
+// key mappings are not used by tls and docker secrets
+func (linter *linter) lintIgnoredKeys(path string, secretType string, keys []string) {
+	for i := 0; i < len(keys); i++ {
+		linter.add(path, "warning", "ignored-key", "key '%s' is ignored for secret-type %s", keys[i], secretType)
+	}
+}
+
+func (linter *linter) lintTls(path string, notes *Notes, values Entry) {
+	linter.lintRequiredField(path, "UserName", values)
+	linter.lintRequiredField(path, "Password", values)
+	linter.lintReferencedField(path, "tls-ca", notes, values)
+	linter.lintReferencedField(path, "tls-passphrase", notes, values)
+	linter.lintReferencedField(path, "keystore-password", notes, values)
+
+	if formats := notes.Get("format"); formats != "" {
+		list := strings.Split(formats, ",")
+		for i := 0; i < len(list); i++ {
+			if _, ok := keystoreKeys[strings.TrimSpace(list[i])]; !ok {
+				linter.add(path, "error", "invalid-option", "unknown secret-format '%s'", list[i])
+			}
+		}
+
+		if notes.Get("keystore-password") == "" {
+			linter.add(path, "error", "missing-field", "secret-format requires secret-keystore-password")
+		}
+	}
+}
+
+func (linter *linter) lintDocker(path string, notes *Notes, values Entry) {
+	if notes.Get("docker-include") == "" {
+		linter.lintRequiredField(path, "URL", values)
+		linter.lintRequiredField(path, "UserName", values)
+		linter.lintRequiredField(path, "Password", values)
+	}
+	linter.lintReferencedField(path, "docker-email", notes, values)
+
+	if format := notes.Get("docker-format"); !contains(dockerFormats, format) {
+		linter.add(path, "error", "invalid-option", "unknown secret-docker-format '%s'", format)
+	}
+}
+
+// field must exist and must not be empty
+func (linter *linter) lintRequiredField(path string, field string, values Entry) {
+	if value, _ := values.GetValue(field); value == "" {
+		linter.add(path, "error", "missing-field", "missing field '%s'", field)
+	}
+}
+
+// field referenced by annotation must exist
+func (linter *linter) lintReferencedField(path string, annotation string, notes *Notes, values Entry) {
+	field := notes.Get(annotation)
+	if field == "" {
+		return
+	}
+
+	if _, ok := values.GetValue(field); !ok {
+		linter.add(path, "error", "missing-field", "field of secret-%s does not exist", annotation)
+	}
+}
+
+// SARIF 2.1.0 log (static analysis results interchange format)
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// convert findings to SARIF, the location is the database file and the entry path
+func createSarifLog(db string, findings []lintFinding) sarifLog {
+	ids := make([]string, 0, len(lintRules))
+	for id := range lintRules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rules := make([]sarifRule, 0, len(ids))
+	for i := 0; i < len(ids); i++ {
+		rules = append(rules, sarifRule{Id: ids[i], ShortDescription: sarifMessage{Text: lintRules[ids[i]]}})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for i := 0; i < len(findings); i++ {
+		finding := &findings[i]
+		results = append(results, sarifResult{
+			RuleId:  finding.Rule,
+			Level:   finding.Severity,
+			Message: sarifMessage{Text: finding.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{Uri: db}},
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: finding.Path, Kind: "member"}},
+			}},
+		})
+	}
+
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "keepass-secret", Version: version, Rules: rules}},
+			Results: results,
+		}},
+	}
+}
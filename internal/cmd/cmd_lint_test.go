@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// create entry map containing all kinds of annotation errors
+func testLintEntryMap() *EntryMap {
+	return testNewEntryMap([]map[string]string{
+		{"path": "/ok", "Title": "ok", "UserName": "admin", "Password": "secret",
+			"Notes": "secret-type=opaque\nsecret-user=UserName\nsecret-password=Password\nsecret-namespace=dev,prod"},
+		{"path": "/typo", "Title": "typo", "Password": "secret",
+			"Notes": "secret-type=opaque\nsecret-password=Pasword\nsecret-pass word=Password\nsecret-password=Password\nsecret-empty=URL\nsecret-namespace=Dev", "URL": ""},
+		{"path": "/unknown", "Title": "unknown", "Notes": "secret-type=opaqe"},
+		{"path": "/untyped", "Title": "untyped", "Notes": "secret-password=Password"},
+		{"path": "/tls", "Title": "Example Cert", "UserName": "crt",
+			"Notes": "secret-type=tls\nsecret-tls-ca=CA\nsecret-format=pkcs12,pem\nsecret-cert=UserName"},
+		{"path": "/docker", "Title": "docker", "URL": "registry.example.com", "UserName": "foo", "Password": "bar",
+			"Notes": "secret-type=docker\nsecret-docker-format=json"},
+	})
+}
+
+// text output
+func TestLintText(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdLint(testLintEntryMap(), "test.kdbx", nil, "", "", &stdout, &stderr)
+
+	if result != 1 {
+		t.Errorf("lint must fail, result=%d", result)
+	}
+
+	expected := strings.Join([]string{
+		"error   /typo annotation 'secret-password' is defined more than once [duplicate-key]",
+		"error   /typo invalid namespace 'Dev' [invalid-namespace]",
+		"error   /typo invalid key name 'pass word' [invalid-key]",
+		"warning /typo value of key 'empty' is empty [empty-value]",
+		"error   /unknown unknown secret-type 'opaqe' [unknown-type]",
+		"warning /untyped annotations are ignored without secret-type [ignored-annotations]",
+		"error   /tls invalid name 'Example Cert' [invalid-name]",
+		"warning /tls key 'cert' is ignored for secret-type tls [ignored-key]",
+		"error   /tls missing field 'Password' [missing-field]",
+		"error   /tls field of secret-tls-ca does not exist [missing-field]",
+		"error   /tls unknown secret-format 'pem' [invalid-option]",
+		"error   /tls secret-format requires secret-keystore-password [missing-field]",
+		"error   /docker unknown secret-docker-format 'json' [invalid-option]",
+	}, "\n") + "\n"
+
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+}
+
+// misspelled field of mapping (the later duplicate line wins)
+func TestLintMissingField(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/typo", "Title": "typo", "Password": "secret", "Notes": "secret-type=opaque\nsecret-password=Pasword"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	CmdLint(entryMap, "test.kdbx", nil, "", "", &stdout, &stderr)
+
+	expected := "error   /typo does not contain the field of key 'password' [unresolved-value]\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+}
+
+// JSON and SARIF output
+func TestLintJsonSarif(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	CmdLint(testLintEntryMap(), "test.kdbx", nil, "", "json", &stdout, &stderr)
+
+	findings := make([]lintFinding, 0)
+	if err := json.Unmarshal([]byte(stdout.String()), &findings); err != nil || len(findings) != 13 {
+		t.Errorf("unexpected JSON %d %v", len(findings), err)
+	}
+
+	stdout = strings.Builder{}
+	CmdLint(testLintEntryMap(), "test.kdbx", nil, "", "sarif", &stdout, &stderr)
+
+	log := sarifLog{}
+	if err := json.Unmarshal([]byte(stdout.String()), &log); err != nil {
+		t.Errorf("invalid SARIF %s", err)
+		return
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 13 {
+		t.Errorf("unexpected SARIF %s", stdout.String())
+		return
+	}
+
+	result := log.Runs[0].Results[0]
+	if result.RuleId != "duplicate-key" || result.Level != "error" || result.Locations[0].LogicalLocations[0].FullyQualifiedName != "/typo" ||
+		result.Locations[0].PhysicalLocation.ArtifactLocation.Uri != "test.kdbx" {
+		t.Errorf("unexpected SARIF result %v", result)
+	}
+}
+
+// valid entries
+func TestLintOk(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/ok", "Title": "ok", "UserName": "admin", "Notes": "secret-type=opaque\nsecret-user=UserName"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	if result := CmdLint(entryMap, "test.kdbx", nil, "", "", &stdout, &stderr); result != 0 || stdout.Len() != 0 {
+		t.Errorf("unexpected result %d %s", result, stdout.String())
+	}
+}
+
+// namespace-scoped annotations without effect
+func TestLintOverrides(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "Password": "secret",
+			"Notes": "secret-type=opaque\nsecret-namespace=dev,prod\nsecret-password=Password\nsecret-dev:password=PasswordDev\nsecret-test:password=Password\nsecret-prod:immutable=true\nconfig-dev:host=URL"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdLint(entryMap, "test.kdbx", nil, "", "", &stdout, &stderr)
+	if result != 1 {
+		t.Errorf("lint must fail, result=%d", result)
+	}
+
+	expected := strings.Join([]string{
+		"error   /db does not contain the field of key 'password' [unresolved-value]",
+		"warning /db annotation 'secret-test:password' is ignored, namespace 'test' is not in secret-namespace [unused-override]",
+		"warning /db annotation 'secret-prod:immutable' is ignored, secret-immutable cannot be set per namespace [unused-override]",
+		"error   /db does not contain the field of key 'host' [unresolved-value]",
+	}, "\n") + "\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// print path and tags of all entries matching the tag filter
+func CmdList(entryMap *EntryMap, filter *TagFilter, stdout io.Writer, stderr io.Writer) int {
+	paths := entryMap.GetPaths()
+	for i := 0; i < len(paths); i++ {
+		path := paths[i]
+		values, _ := entryMap.GetValues(path)
+		tags := filter.EntryTags(NewNotes(values), values)
+		if !filter.Match(tags) {
+			continue
+		}
+
+		fmt.Fprintf(stdout, "%s tags=%s\n", path, strings.Join(tags, ","))
+	}
+
+	return 0 // success
+}
@@ -1,166 +1,326 @@
 package cmd
 
 import (
-	"encoding/base64"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // export all marked entries as Kubernetes secrets YAML
-// supports  opaque (regular) and docker secrets
-func CmdSecrets(entryMap *EntryMap, out string, tag string, stdout io.Writer, stderr io.Writer) int {
-	paths := entryMap.GetPaths()
+// supports  opaque (regular), docker and tls secrets
+// entries with "config-" lines (or type configmap) are exported as ConfigMap
+// the output format is one of manifest (default), kustomize or helm
+// the resource name is the entry title unless overridden by "secret-name" or the name template
+// in strict mode any warning (e.g. missing field) aborts before the output is written
+// with stringData text values of secrets are written as plain text (manifest format only)
+func CmdSecrets(entryMap *EntryMap, out string, filter *TagFilter, format string, nameTemplate string, strict bool, stringData bool, diffAgainst string, stdout io.Writer, stderr io.Writer) int {
+	warnings := &warningCounter{writer: stderr, count: entryMap.GetWarnings()} // includes unresolved references
+	resources, ok := collectResources(entryMap, filter, nameTemplate, stringData, stdout, warnings)
+	if !ok || !checkDuplicates(resources, stderr) {
+		return 1 // failure
+	}
+
 	lines := make([]string, 0)
+	switch format {
+	case "kustomize":
+		// files are rendered while writing
+	case "helm":
+		if lines, ok = renderHelmValues(resources, stderr); !ok {
+			return 1 // failure
+		}
+	default:
+		lines = renderManifests(resources)
+	}
+
+	if strict && warnings.count > 0 {
+		fmt.Fprintf(stderr, "%d warning(s) in strict mode, output not written\n", warnings.count)
+		return 3 // strict mode failure
+	}
+
+	if diffAgainst != "" {
+		return diffManifest(resources, diffAgainst, stdout, stderr) // compare instead of writing
+	}
+
+	if format == "kustomize" {
+		return writeKustomize(out, resources, stderr)
+	}
+
+	return writeFile(out, &lines, stderr)
+}
+
+// counts the warnings (lines) written to stderr
+type warningCounter struct {
+	writer io.Writer
+	count  int
+}
+
+func (counter *warningCounter) Write(p []byte) (int, error) {
+	counter.count += strings.Count(string(p), "\n")
+	return counter.writer.Write(p)
+}
+
+// create Secrets and ConfigMaps of all marked entries
+// stringData is the default of the "secret-string-data" annotation
+// returns false if the name template is invalid or cannot be applied
+func collectResources(entryMap *EntryMap, filter *TagFilter, nameTemplate string, stringData bool, stdout io.Writer, stderr io.Writer) ([]Resource, bool) {
+	tmpl, err := parseNameTemplate(nameTemplate)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid name template: %s\n", err)
+		return nil, false
+	}
+
+	valid := true
+	paths := entryMap.GetPaths()
+	resources := make([]Resource, 0)
 	for i := 0; i < len(paths); i++ {
 		path := paths[i]
 		if values, ok := entryMap.GetValues(path); ok {
 			notes := NewNotes(values)
 
-			tags := strings.Split(notes.Get("tags"), ",")
+			tags := filter.EntryTags(notes, values)
 			namespaces := strings.Split(notes.Get("namespace"), ",")
 
+			if !filter.Match(tags) {
+				continue
+			}
+
+			errors := notes.GetErrors()
+			for j := 0; j < len(errors); j++ {
+				fmt.Fprintf(stderr, "entry '%s' contains invalid annotation in %s\n", path, errors[j])
+			}
+
+			if notes.Get("type") == "" && len(notes.GetConfigKeys()) == 0 {
+				continue
+			}
+
+			name, err := resolveName(path, notes, values, tags, tmpl)
+			if err != nil {
+				fmt.Fprintf(stderr, "cannot apply name template to entry '%s': %s\n", path, err)
+				valid = false
+				continue
+			} else if name != "" && !isValidName(name) {
+				fmt.Fprintf(stderr, "invalid name '%s' of entry '%s'\n", name, path)
+				valid = false
+				continue
+			}
+
+			first := len(resources)
 			for j := 0; j < len(namespaces); j++ {
 				namespace := namespaces[j]
-				if include(tags, tag) {
-					secretType := notes.Get("type")
-					switch secretType {
-					case "opaque":
-						createOpaqueSecret(path, namespace, notes, values, &lines, stdout, stderr)
-					case "docker":
-						createDockerSecret(path, namespace, values, &lines, stdout, stderr)
-					case "tls":
-						createTlsSecret(path, namespace, values, &lines, stdout, stderr)
-					}
+				namespaceNotes := notes.ForNamespace(namespace) // e.g. "secret-dev:password=PasswordDev"
+				secretType := notes.Get("type")
+				switch secretType {
+				case "opaque":
+					createOpaqueSecret(path, name, namespace, namespaceNotes, values, &resources, stdout, stderr)
+				case "docker":
+					createDockerSecret(path, name, namespace, namespaceNotes, values, entryMap, &resources, stdout, stderr)
+				case "tls":
+					createTlsSecret(path, name, namespace, namespaceNotes, values, &resources, stdout, stderr)
+				case "configmap":
+					createConfigMap(path, name, namespace, namespaceNotes, values, &resources, stdout, stderr)
+				case "":
+					// ConfigMap only
+				default:
+					fmt.Fprintf(stderr, "unknown type '%s' of entry '%s', ignored\n", secretType, path)
+				}
+
+				if secretType != "configmap" && len(namespaceNotes.GetConfigKeys()) > 0 {
+					createConfigMap(path, name, namespace, namespaceNotes, values, &resources, stdout, stderr)
 				}
 			}
+
+			applyResourceOptions(resources[first:], path, notes, stringData, stdout, stderr)
 		}
 	}
 
-	return writeFile(out, &lines, stderr)
+	return resources, valid
 }
 
-// check if entry should be included
-// apply tag filter
-func include(tags []string, tag string) bool {
-	if tag == "" {
-		return true
+// apply the output options of an entry to the resources created for the entry
+// "secret-name-hash=true" appends a hash of the data to the name (pods roll on change)
+func applyResourceOptions(resources []Resource, path string, notes *Notes, stringData bool, stdout io.Writer, stderr io.Writer) {
+	stringData = boolAnnotation(path, notes, "string-data", stringData, stderr)
+	immutable := boolAnnotation(path, notes, "immutable", false, stderr)
+	nameHash := boolAnnotation(path, notes, "name-hash", false, stderr)
+	reported := make(map[string]bool) // report each name once (not per namespace)
+	for i := 0; i < len(resources); i++ {
+		resource := &resources[i]
+		resource.stringData = stringData
+		resource.immutable = immutable
+		if nameHash {
+			hash := resource.Hash()
+			resource.name = resource.name + "-" + hash
+			if line := fmt.Sprintf("%s name=%s hash=%s\n", strings.ToLower(resource.kind), resource.name, hash); !reported[line] {
+				reported[line] = true
+				fmt.Fprint(stdout, line)
+			}
+		}
 	}
+}
 
-	// tag specified -> entry must contain tag
-	for i := 0; i < len(tags); i++ {
-		if tags[i] == tag {
-			return true
-		}
+// returns the value of a boolean annotation, or the default if it is not set or invalid
+func boolAnnotation(path string, notes *Notes, key string, defaultValue bool, stderr io.Writer) bool {
+	text := notes.Get(key)
+	if text == "" {
+		return defaultValue
 	}
 
-	return false
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		fmt.Fprintf(stderr, "entry '%s' contains invalid value '%s' of secret-%s\n", path, text, key)
+		return defaultValue
+	}
+
+	return value
 }
 
 // create opaque (regular) secret
-func createOpaqueSecret(path string, namespace string, notes *Notes, values Entry, lines *[]string, stdout io.Writer, stderr io.Writer) {
-	title, _ := values.GetValue("Title")
-	if title == "" {
-		fmt.Fprintf(stderr, "missing title for entry '%s'\n", path)
-		return
+func createOpaqueSecret(path string, name string, namespace string, notes *Notes, values Entry, resources *[]Resource, stdout io.Writer, stderr io.Writer) {
+	if name == "" {
+		name, _ = values.GetValue("Title") // entry title is the default name
 	}
 
-	if len(*lines) > 0 {
-		*lines = append(*lines, "")
-		*lines = append(*lines, "---")
+	if name == "" {
+		fmt.Fprintf(stderr, "missing title for entry '%s'\n", path)
+		return
 	}
 
-	*lines = append(*lines, "apiVersion: v1")
-	*lines = append(*lines, "kind: Secret")
-	*lines = append(*lines, "metadata:")
-	*lines = append(*lines, "  name: \""+title+"\"")
-	if len(namespace) > 0 {
-		*lines = append(*lines, "  namespace: \""+namespace+"\"")
-	}
-	*lines = append(*lines, "type: Opaque")
-	*lines = append(*lines, "data:")
+	resource := NewResource("Secret", name, namespace, "Opaque")
+	resource.path = path
 
 	secretKeys := notes.GetKeys()
 
-	fmt.Fprintf(stdout, "secret opaque name=%s fields=%s\n", title, strings.Join(secretKeys, ","))
+	fmt.Fprintf(stdout, "secret opaque name=%s fields=%s\n", name, strings.Join(secretKeys, ","))
 
 	for i := 0; i < len(secretKeys); i++ {
 		secretKey := secretKeys[i]
-		valuesKey := notes.Get(secretKey)
-		value, ok := values.GetValue(valuesKey)
-		if ok {
-			value64 := base64.StdEncoding.EncodeToString([]byte(value))
-			secretKey = strings.TrimPrefix(secretKey, ":")
-			*lines = append(*lines, "  "+secretKey+": \""+value64+"\"")
+		secretKey = strings.TrimPrefix(secretKey, ":")
+		value, err := resolveValue(secretKey, notes.Get(secretKeys[i]), values)
+		if err == nil {
+			if len(value) == 0 {
+				fmt.Fprintf(stderr, "entry '%s' contains empty value for key '%s'\n", path, secretKey)
+			}
+			resource.SetData(secretKey, value)
 		} else {
-			fmt.Fprintf(stderr, "entry '%s' does not contain value '%s'\n", path, valuesKey)
+			fmt.Fprintf(stderr, "entry '%s' %s\n", path, err)
 		}
 	}
+
+	*resources = append(*resources, *resource)
 }
 
-// create docker secret
-func createDockerSecret(path string, namespace string, values Entry, lines *[]string, stdout io.Writer, stderr io.Writer) {
+// create ConfigMap with plain (not base64 encoded) values
+// data is taken from the "config-" lines
+// for type configmap the "secret-" mappings are included as well
+func createConfigMap(path string, name string, namespace string, notes *Notes, values Entry, resources *[]Resource, stdout io.Writer, stderr io.Writer) {
+	if name == "" {
+		name, _ = values.GetValue("Title") // entry title is the default name
+	}
 
-	title, _ := values.GetValue("Title")
-	if title == "" {
+	if name == "" {
 		fmt.Fprintf(stderr, "missing title for entry '%s'\n", path)
 		return
 	}
 
-	username, _ := values.GetValue("UserName")
-	if username == "" {
-		fmt.Fprintf(stderr, "missing UserName for entry '%s'\n", path)
-		return
+	configKeys := make([]string, 0)
+	fields := make([]string, 0)
+	if notes.Get("type") == "configmap" {
+		secretKeys := notes.GetKeys()
+		for i := 0; i < len(secretKeys); i++ {
+			configKeys = append(configKeys, secretKeys[i])
+			fields = append(fields, notes.Get(secretKeys[i]))
+		}
 	}
 
-	password, _ := values.GetValue("Password")
-	if password == "" {
-		fmt.Fprintf(stderr, "missing Password for entry '%s'\n", path)
-		return
+	keys := notes.GetConfigKeys()
+	for i := 0; i < len(keys); i++ {
+		configKeys = append(configKeys, keys[i])
+		fields = append(fields, notes.GetConfig(keys[i]))
 	}
 
-	url, _ := values.GetValue("URL")
-	if url == "" {
-		fmt.Fprintf(stderr, "missing URL for entry '%s'\n", path)
+	resource := NewResource("ConfigMap", name, namespace, "")
+	resource.path = path
+
+	fmt.Fprintf(stdout, "configmap name=%s fields=%s\n", name, strings.Join(configKeys, ","))
+
+	for i := 0; i < len(configKeys); i++ {
+		configKey := strings.TrimPrefix(configKeys[i], ":")
+		value, err := resolveValue(configKey, fields[i], values)
+		if err == nil {
+			if len(value) == 0 {
+				fmt.Fprintf(stderr, "entry '%s' contains empty value for key '%s'\n", path, configKey)
+			}
+			resource.SetData(configKey, value)
+		} else {
+			fmt.Fprintf(stderr, "entry '%s' %s\n", path, err)
+		}
+	}
+
+	*resources = append(*resources, *resource)
+}
+
+// create docker secret
+// the registry is defined by the fields URL, UserName and Password (email optional)
+// additional registry entries can be aggregated via "secret-docker-include"
+// "secret-docker-format=dockercfg" creates a legacy kubernetes.io/dockercfg secret
+func createDockerSecret(path string, name string, namespace string, notes *Notes, values Entry, entryMap *EntryMap, resources *[]Resource, stdout io.Writer, stderr io.Writer) {
+
+	if name == "" {
+		name, _ = values.GetValue("Title") // entry title is the default name
+	}
+
+	if name == "" {
+		fmt.Fprintf(stderr, "missing title for entry '%s'\n", path)
 		return
 	}
 
-	fmt.Fprintf(stdout, "secret docker name=%s url=%s username=%s\n", title, url, username)
+	config := NewDockerConfig()
+	includes := notes.Get("docker-include")
+	if url, _ := values.GetValue("URL"); includes == "" || url != "" {
+		if !config.Add(path, notes, values, stderr) {
+			return
+		}
+	}
 
-	if len(*lines) > 0 {
-		*lines = append(*lines, "")
-		*lines = append(*lines, "---")
+	if includes != "" {
+		config.Include(path, strings.Split(includes, ","), entryMap, stderr)
 	}
 
-	email := "mail@example.de"
-	auth := username + ":" + password
-	auth64 := base64.StdEncoding.EncodeToString([]byte(auth))
+	if len(config.urls) == 0 {
+		fmt.Fprintf(stderr, "no registry found for entry '%s'\n", path)
+		return
+	}
 
-	secretJson := "{\"auths\":{\"" + url +
-		"\":{\"username\":\"" + username +
-		"\",\"password\":\"" + password +
-		"\",\"email\":\"" + email +
-		"\",\"auth\":\"" + auth64 + "\"}}}"
-	secret64 := base64.StdEncoding.EncodeToString([]byte(secretJson))
+	fmt.Fprintf(stdout, "secret docker name=%s url=%s username=%s\n", name, strings.Join(config.urls, ","), strings.Join(config.GetUserNames(), ","))
 
-	*lines = append(*lines, "apiVersion: v1")
-	*lines = append(*lines, "kind: Secret")
-	*lines = append(*lines, "metadata:")
-	*lines = append(*lines, "  name: \""+title+"\"")
-	if len(namespace) > 0 {
-		*lines = append(*lines, "  namespace: \""+namespace+"\"")
+	var resource *Resource
+	if notes.Get("docker-format") == "dockercfg" {
+		resource = NewResource("Secret", name, namespace, "kubernetes.io/dockercfg")
+		resource.SetData(".dockercfg", config.MarshalDockerCfg())
+	} else {
+		resource = NewResource("Secret", name, namespace, "kubernetes.io/dockerconfigjson")
+		resource.SetData(".dockerconfigjson", config.MarshalDockerConfigJson())
 	}
 
-	*lines = append(*lines, "type: kubernetes.io/dockerconfigjson")
-	*lines = append(*lines, "data:")
-	*lines = append(*lines, "  .dockerconfigjson: "+secret64)
+	resource.path = path
+	*resources = append(*resources, *resource)
 }
 
-// create docker secret
-func createTlsSecret(path string, namespace string, values Entry, lines *[]string, stdout io.Writer, stderr io.Writer) {
+// create tls secret
+// the certificate (optionally followed by its chain) is taken from the UserName field,
+// the private key from the Password field
+// "secret-tls-ca=<field>" adds the CA certificates as ca.crt
+// "secret-tls-passphrase=<field>" decrypts an encrypted private key
+// "secret-format=pkcs12,jks" adds keystores protected by "secret-keystore-password=<field>"
+func createTlsSecret(path string, name string, namespace string, notes *Notes, values Entry, resources *[]Resource, stdout io.Writer, stderr io.Writer) {
+
+	if name == "" {
+		name, _ = values.GetValue("Title") // entry title is the default name
+	}
 
-	title, _ := values.GetValue("Title")
-	if title == "" {
+	if name == "" {
 		fmt.Fprintf(stderr, "missing title for entry '%s'\n", path)
 		return
 	}
@@ -177,25 +337,75 @@ func createTlsSecret(path string, namespace string, values Entry, lines *[]strin
 		return
 	}
 
-	fmt.Fprintf(stdout, "secret tls name=%s crt=%s key=%s\n", title, username[:27], password[:27])
+	certs, err := parseCertificates(username)
+	if err != nil {
+		fmt.Fprintf(stderr, "entry '%s' contains invalid certificate: %s\n", path, err)
+		return
+	}
 
-	if len(*lines) > 0 {
-		*lines = append(*lines, "")
-		*lines = append(*lines, "---")
+	passphrase := ""
+	if field := notes.Get("tls-passphrase"); field != "" {
+		var ok bool
+		if passphrase, ok = values.GetValue(field); !ok {
+			fmt.Fprintf(stderr, "entry '%s' does not contain the field of secret-tls-passphrase\n", path)
+			return
+		}
 	}
 
-	crt := base64.StdEncoding.EncodeToString([]byte(username))
-	key := base64.StdEncoding.EncodeToString([]byte(password))
+	key, keyText, err := parsePrivateKey(password, passphrase)
+	if err != nil {
+		fmt.Fprintf(stderr, "entry '%s' contains invalid private key: %s\n", path, err)
+		return
+	}
+
+	if !keyMatches(certs[0], key) {
+		fmt.Fprintf(stderr, "private key of entry '%s' does not match certificate\n", path)
+		return
+	}
 
-	*lines = append(*lines, "apiVersion: v1")
-	*lines = append(*lines, "kind: Secret")
-	*lines = append(*lines, "metadata:")
-	*lines = append(*lines, "  name: \""+title+"\"")
-	if len(namespace) > 0 {
-		*lines = append(*lines, "  namespace: \""+namespace+"\"")
+	now := time.Now()
+	days := daysRemaining(certs[0], now)
+	if certs[0].NotAfter.Before(now) {
+		fmt.Fprintf(stderr, "certificate of entry '%s' expired on %s\n", path, certs[0].NotAfter.UTC().Format(time.DateOnly))
+	} else if days < expiryWarnDays {
+		fmt.Fprintf(stderr, "certificate of entry '%s' expires in %d days\n", path, days)
 	}
-	*lines = append(*lines, "type: kubernetes.io/tls")
-	*lines = append(*lines, "data:")
-	*lines = append(*lines, "  tls.crt: \""+crt+"\"")
-	*lines = append(*lines, "  tls.key: \""+key+"\"")
+
+	fmt.Fprintf(stdout, "secret tls name=%s %s\n", name, describeCertificate(certs[0]))
+
+	resource := NewResource("Secret", name, namespace, "kubernetes.io/tls")
+	resource.path = path
+	resource.SetData("tls.crt", []byte(username))
+	resource.SetData("tls.key", []byte(keyText))
+
+	if field := notes.Get("tls-ca"); field != "" {
+		ca, ok := values.GetValue(field)
+		if !ok {
+			fmt.Fprintf(stderr, "entry '%s' does not contain the field of secret-tls-ca\n", path)
+			return
+		}
+
+		if _, err := parseCertificates(ca); err != nil {
+			fmt.Fprintf(stderr, "entry '%s' contains invalid CA certificate: %s\n", path, err)
+			return
+		}
+
+		resource.SetData("ca.crt", []byte(ca))
+	}
+
+	// keystores for Java applications e.g. secret-format=pkcs12,jks
+	if formats := notes.Get("format"); formats != "" {
+		list := strings.Split(formats, ",")
+		for i := 0; i < len(list); i++ {
+			format := strings.TrimSpace(list[i])
+			keystore, err := createKeystore(format, name, notes, values)
+			if err != nil {
+				fmt.Fprintf(stderr, "cannot create %s keystore of entry '%s': %s\n", format, path, err)
+				return
+			}
+			resource.SetData(keystoreKeys[format], keystore)
+		}
+	}
+
+	*resources = append(*resources, *resource)
 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -173,9 +174,9 @@ func TestSecretsTagC(t *testing.T) {
 func TestSecretsDockerSecretMissingTitle(t *testing.T) {
 	stdout := strings.Builder{}
 	stderr := strings.Builder{}
-	lines := make([]string, 0)
+	resources := make([]Resource, 0)
 	values := NewEntry()
-	createDockerSecret("e0", "", *values, &lines, &stdout, &stderr)
+	createDockerSecret("e0", "", "", NewNotes(*values), *values, nil, &resources, &stdout, &stderr)
 
 	expected := "missing title for entry 'e0'\n"
 	actual := stderr.String()
@@ -190,10 +191,10 @@ func TestSecretsDockerSecretMissingTitle(t *testing.T) {
 func TestSecretsDockerSecretMissingUserName(t *testing.T) {
 	stdout := strings.Builder{}
 	stderr := strings.Builder{}
-	lines := make([]string, 0)
+	resources := make([]Resource, 0)
 	values := NewEntry()
 	values.SetValue("Title", "Title")
-	createDockerSecret("e0", "", *values, &lines, &stdout, &stderr)
+	createDockerSecret("e0", "", "", NewNotes(*values), *values, nil, &resources, &stdout, &stderr)
 
 	expected := "missing UserName for entry 'e0'\n"
 	actual := stderr.String()
@@ -208,11 +209,11 @@ func TestSecretsDockerSecretMissingUserName(t *testing.T) {
 func TestSecretsDockerSecretMissingPassword(t *testing.T) {
 	stdout := strings.Builder{}
 	stderr := strings.Builder{}
-	lines := make([]string, 0)
+	resources := make([]Resource, 0)
 	values := NewEntry()
 	values.SetValue("Title", "Title")
 	values.SetValue("UserName", "UserName")
-	createDockerSecret("e0", "", *values, &lines, &stdout, &stderr)
+	createDockerSecret("e0", "", "", NewNotes(*values), *values, nil, &resources, &stdout, &stderr)
 
 	expected := "missing Password for entry 'e0'\n"
 	actual := stderr.String()
@@ -227,12 +228,12 @@ func TestSecretsDockerSecretMissingPassword(t *testing.T) {
 func TestSecretsDockerSecretMissingURL(t *testing.T) {
 	stdout := strings.Builder{}
 	stderr := strings.Builder{}
-	lines := make([]string, 0)
+	resources := make([]Resource, 0)
 	values := NewEntry()
 	values.SetValue("Title", "Title")
 	values.SetValue("UserName", "UserName")
 	values.SetValue("Password", "Password")
-	createDockerSecret("e0", "", *values, &lines, &stdout, &stderr)
+	createDockerSecret("e0", "", "", NewNotes(*values), *values, nil, &resources, &stdout, &stderr)
 
 	expected := "missing URL for entry 'e0'\n"
 	actual := stderr.String()
@@ -247,10 +248,10 @@ func TestSecretsDockerSecretMissingURL(t *testing.T) {
 func TestSecretsOpaqueSecretMissingTitle(t *testing.T) {
 	stdout := strings.Builder{}
 	stderr := strings.Builder{}
-	lines := make([]string, 0)
+	resources := make([]Resource, 0)
 	values := NewEntry()
 	notes := NewNotes(*values)
-	createOpaqueSecret("e0", "", notes, *values, &lines, &stdout, &stderr)
+	createOpaqueSecret("e0", "", "", notes, *values, &resources, &stdout, &stderr)
 
 	expected := "missing title for entry 'e0'\n"
 	actual := stderr.String()
@@ -265,14 +266,14 @@ func TestSecretsOpaqueSecretMissingTitle(t *testing.T) {
 func TestSecretsOpaqueSecretMissingValue(t *testing.T) {
 	stdout := strings.Builder{}
 	stderr := strings.Builder{}
-	lines := make([]string, 0)
+	resources := make([]Resource, 0)
 	values := NewEntry()
 	values.SetValue("Title", "Title")
 	values.SetValue("Notes", "secret-password=Password")
 	notes := NewNotes(*values)
-	createOpaqueSecret("e1", "", notes, *values, &lines, &stdout, &stderr)
+	createOpaqueSecret("e1", "", "", notes, *values, &resources, &stdout, &stderr)
 
-	expected := "entry 'e1' does not contain value 'Password'\n"
+	expected := "entry 'e1' does not contain the field of key 'password'\n"
 	actual := stderr.String()
 	if expected != actual {
 		t.Errorf("expected: %s", expected)
@@ -280,3 +281,541 @@ func TestSecretsOpaqueSecretMissingValue(t *testing.T) {
 		return
 	}
 }
+
+// export ConfigMap instead of and alongside a secret
+func TestSecretsConfigMap(t *testing.T) {
+	out := "test/test.yaml"
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/app", "Title": "app", "URL": "https://example.com/?a=1&b=2", "host": "db\"1\"",
+			"Notes": "secret-type=configmap\nsecret-url=URL\nconfig-host=host\nsecret-namespace=dev"},
+		{"path": "/db", "Title": "db", "UserName": "admin", "Password": "1234", "port": "5432",
+			"Notes": "secret-type=opaque\nsecret-password=Password\nconfig-username=UserName\nconfig-port=port"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdSecrets(entryMap, out, nil, "manifest", "", false, false, "", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("secrets failed, result=%d", result)
+		return
+	}
+
+	expected := []string{
+		"apiVersion: v1",
+		"kind: ConfigMap",
+		"metadata:",
+		"  name: \"app\"",
+		"  namespace: \"dev\"",
+		"data:",
+		"  url: \"https://example.com/?a=1&b=2\"",
+		"  host: \"db\\\"1\\\"\"",
+		"",
+		"---",
+		"apiVersion: v1",
+		"kind: Secret",
+		"metadata:",
+		"  name: \"db\"",
+		"type: Opaque",
+		"data:",
+		"  password: \"MTIzNA==\"",
+		"",
+		"---",
+		"apiVersion: v1",
+		"kind: ConfigMap",
+		"metadata:",
+		"  name: \"db\"",
+		"data:",
+		"  username: \"admin\"",
+		"  port: \"5432\"",
+		"",
+	}
+
+	compareLines(readLines(out, t), expected, t)
+
+	if len(stderr.String()) != 0 {
+		t.Errorf("stderr not empty: %s", stderr.String())
+	}
+
+	line := "configmap name=db fields=username,port"
+	if !strings.Contains(stdout.String(), line) {
+		t.Errorf("stdout missing line: %s", stdout.String())
+	}
+
+	testDeleteFile(out, t)
+}
+
+// export ConfigMap with missing value
+func TestSecretsConfigMapMissingValue(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources := make([]Resource, 0)
+	values := NewEntry()
+	values.SetValue("Title", "Title")
+	values.SetValue("Notes", "config-url=URL")
+	notes := NewNotes(*values)
+	createConfigMap("e1", "", "", notes, *values, &resources, &stdout, &stderr)
+
+	expected := "entry 'e1' does not contain the field of key 'url'\n"
+	actual := stderr.String()
+	if expected != actual {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+		return
+	}
+}
+
+// export secrets as Kustomize secretGenerator
+func TestSecretsKustomize(t *testing.T) {
+	out := "test/kustomize"
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "admin", "Password": "1234", "cert": "line1\nline2", "URL": "db.local",
+			"Notes": "secret-type=opaque\nsecret-username=UserName\nsecret-cert=cert\nconfig-host=URL\nsecret-namespace=dev,prod"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdSecrets(entryMap, out, nil, "kustomize", "", false, false, "", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("secrets failed, result=%d", result)
+		return
+	}
+
+	expected := []string{
+		"apiVersion: kustomize.config.k8s.io/v1beta1",
+		"kind: Kustomization",
+		"generatorOptions:",
+		"  disableNameSuffixHash: true",
+		"secretGenerator:",
+		"- name: \"db\"",
+		"  namespace: \"dev\"",
+		"  type: \"Opaque\"",
+		"  envs:",
+		"  - \"secrets/dev-db.env\"",
+		"  files:",
+		"  - \"cert=secrets/dev-db/cert\"",
+		"- name: \"db\"",
+		"  namespace: \"prod\"",
+		"  type: \"Opaque\"",
+		"  envs:",
+		"  - \"secrets/prod-db.env\"",
+		"  files:",
+		"  - \"cert=secrets/prod-db/cert\"",
+		"configMapGenerator:",
+		"- name: \"db\"",
+		"  namespace: \"dev\"",
+		"  envs:",
+		"  - \"configmaps/dev-db.env\"",
+		"- name: \"db\"",
+		"  namespace: \"prod\"",
+		"  envs:",
+		"  - \"configmaps/prod-db.env\"",
+		"",
+	}
+
+	compareLines(readLines(out+"/kustomization.yaml", t), expected, t)
+	compareLines(readLines(out+"/secrets/dev-db.env", t), []string{"username=admin", ""}, t)
+	compareLines(readLines(out+"/secrets/dev-db/cert", t), []string{"line1", "line2"}, t)
+	compareLines(readLines(out+"/configmaps/prod-db.env", t), []string{"host=db.local", ""}, t)
+
+	if err := os.RemoveAll(out); err != nil {
+		t.Errorf("cannot delete %s", out)
+	}
+}
+
+// invalid keys and names are rejected before any file is written
+func TestSecretsKustomizeInvalid(t *testing.T) {
+	tests := []struct {
+		notes    string
+		expected string
+	}{
+		{"secret-type=opaque\nsecret-../../escaped<<EOF\nliteral:x\ny\nEOF", "invalid key '../../escaped' of secret db\n"},
+		{"secret-type=opaque\nsecret-..=Password", "invalid key '..' of secret db\n"},
+		{"secret-type=opaque\nsecret-password=Password\nsecret-name=group/db", "invalid name 'group/db' of entry '/db'\n"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		out := filepath.Join(t.TempDir(), "kustomize")
+		entryMap := testNewEntryMap([]map[string]string{
+			{"path": "/db", "Title": "db", "Password": "1234", "Notes": tests[i].notes},
+		})
+
+		stdout := strings.Builder{}
+		stderr := strings.Builder{}
+		if result := CmdSecrets(entryMap, out, nil, "kustomize", "", false, false, "", &stdout, &stderr); result != 1 {
+			t.Errorf("invalid resource must fail, result=%d", result)
+		}
+
+		if !strings.HasSuffix(stderr.String(), tests[i].expected) {
+			t.Errorf("expected: %s", tests[i].expected)
+			t.Errorf("actual:   %s", stderr.String())
+		}
+
+		if _, err := os.Stat(out); !os.IsNotExist(err) {
+			t.Errorf("%s must not be written", out)
+		}
+	}
+}
+
+// export secrets as Helm values
+func TestSecretsHelm(t *testing.T) {
+	out := "test/values.yaml"
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "admin", "URL": "db.local",
+			"Notes": "secret-type=opaque\nsecret-username=UserName\nconfig-host=URL\nsecret-namespace=dev,prod"},
+		{"path": "/app", "Title": "app", "Password": "1234",
+			"Notes": "secret-type=opaque\nsecret-password=Password"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdSecrets(entryMap, out, nil, "helm", "", false, false, "", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("secrets failed, result=%d", result)
+		return
+	}
+
+	expected := []string{
+		"secrets:",
+		"  \"db\":",
+		"    type: \"Opaque\"",
+		"    namespaces:",
+		"    - \"dev\"",
+		"    - \"prod\"",
+		"    data:",
+		"      \"username\": \"YWRtaW4=\"",
+		"  \"app\":",
+		"    type: \"Opaque\"",
+		"    data:",
+		"      \"password\": \"MTIzNA==\"",
+		"configMaps:",
+		"  \"db\":",
+		"    namespaces:",
+		"    - \"dev\"",
+		"    - \"prod\"",
+		"    data:",
+		"      \"host\": \"db.local\"",
+		"",
+	}
+
+	compareLines(readLines(out, t), expected, t)
+
+	if len(stderr.String()) != 0 {
+		t.Errorf("stderr not empty: %s", stderr.String())
+	}
+
+	testDeleteFile(out, t)
+}
+
+// Helm values, same name with different data
+func TestSecretsHelmNamespaceData(t *testing.T) {
+	resources := []Resource{
+		*NewResource("Secret", "db", "dev", "Opaque"),
+		*NewResource("Secret", "db", "prod", "Opaque"),
+	}
+	resources[0].SetData("password", []byte("dev"))
+	resources[1].SetData("password", []byte("prod"))
+
+	stderr := strings.Builder{}
+	lines, ok := renderHelmValues(resources, &stderr)
+	if !ok {
+		t.Errorf("render failed: %s", stderr.String())
+		return
+	}
+
+	expected := []string{
+		"secrets:",
+		"  \"db\":",
+		"    type: \"Opaque\"",
+		"    namespaces:",
+		"    - \"dev\"",
+		"    - \"prod\"",
+		"    data:",
+		"      \"password\": \"ZGV2\"",
+		"    namespaceData:",
+		"      \"prod\":",
+		"        \"password\": \"cHJvZA==\"",
+	}
+	if strings.Join(expected, "\n") != strings.Join(lines, "\n") {
+		t.Errorf("expected: %v", expected)
+		t.Errorf("actual:   %v", lines)
+	}
+}
+
+// same name with different type can not be merged
+func TestSecretsHelmConflict(t *testing.T) {
+	resources := []Resource{
+		*NewResource("Secret", "db", "dev", "Opaque"),
+		*NewResource("Secret", "db", "prod", "kubernetes.io/basic-auth"),
+	}
+
+	stderr := strings.Builder{}
+	_, ok := renderHelmValues(resources, &stderr)
+	if ok {
+		t.Errorf("expected conflict")
+	}
+
+	expected := "Secret 'db' in namespace 'prod' conflicts with previous definition\n"
+	actual := stderr.String()
+	if expected != actual {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+}
+
+// secret name defined by "secret-name" and name template
+func TestSecretsNameTemplate(t *testing.T) {
+	out := "test/test.yaml"
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/Backend/db/postgres", "Title": "postgres", "Password": "1234",
+			"Notes": "secret-type=opaque\nsecret-password=Password\nsecret-tags=prod"},
+		{"path": "/Frontend/postgres", "Title": "postgres", "Password": "1234",
+			"Notes": "secret-type=opaque\nsecret-password=Password\nsecret-name=frontend-db"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	nameTemplate := "{{replace .Group \"/\" \"-\" | lower}}-{{.Title}}{{range .Tags}}-{{.}}{{end}}"
+	result := CmdSecrets(entryMap, out, nil, "manifest", nameTemplate, false, false, "", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("secrets failed, result=%d %s", result, stderr.String())
+		return
+	}
+
+	lines := readLines(out, t)
+	if len(lines) < 13 || lines[3] != "  name: \"backend-db-postgres-prod\"" || lines[12] != "  name: \"frontend-db\"" {
+		t.Errorf("unexpected names: %v", lines)
+	}
+
+	testDeleteFile(out, t)
+}
+
+// two entries render the same namespace/name
+func TestSecretsDuplicateName(t *testing.T) {
+	out := "test/duplicate.yaml"
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/a/postgres", "Title": "postgres", "Password": "1234",
+			"Notes": "secret-type=opaque\nsecret-password=Password\nsecret-namespace=dev,prod"},
+		{"path": "/b/postgres", "Title": "postgres", "Password": "1234",
+			"Notes": "secret-type=opaque\nsecret-password=Password\nsecret-namespace=prod"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdSecrets(entryMap, out, nil, "manifest", "", false, false, "", &stdout, &stderr)
+	if result == 0 {
+		t.Errorf("secrets must fail")
+	}
+
+	expected := "Secret 'prod/postgres' is created by entry '/a/postgres' and entry '/b/postgres'\n"
+	actual := stderr.String()
+	if expected != actual {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("%s must not be written", out)
+	}
+
+	// unique names by template
+	stderr.Reset()
+	result = CmdSecrets(entryMap, out, nil, "manifest", "{{.Group}}-{{.Title}}", false, false, "", &stdout, &stderr)
+	if result != 0 {
+		t.Errorf("secrets failed, result=%d %s", result, stderr.String())
+		return
+	}
+
+	testDeleteFile(out, t)
+}
+
+// invalid name template
+func TestSecretsInvalidNameTemplate(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/a/postgres", "Title": "postgres", "Notes": "secret-type=opaque"},
+	})
+
+	out := filepath.Join(t.TempDir(), "invalid.yaml")
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdSecrets(entryMap, out, nil, "manifest", "{{.Group", false, false, "", &stdout, &stderr)
+	if result == 0 {
+		t.Errorf("secrets must fail")
+	}
+
+	if !strings.HasPrefix(stderr.String(), "invalid name template: ") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+
+	stderr.Reset()
+	result = CmdSecrets(entryMap, out, nil, "manifest", "{{.Invalid}}", false, false, "", &stdout, &stderr)
+	if result == 0 {
+		t.Errorf("secrets must fail")
+	}
+
+	if !strings.HasPrefix(stderr.String(), "cannot apply name template to entry '/a/postgres': ") {
+		t.Errorf("unexpected stderr: %s", stderr.String())
+	}
+
+	// rendered names must be valid resource names
+	entryMap = testNewEntryMap([]map[string]string{
+		{"path": "/a/b/postgres", "Title": "postgres", "Password": "1234", "Notes": "secret-type=opaque\nsecret-password=Password"},
+		{"path": "/redis", "Title": "redis", "Password": "1234", "Notes": "secret-type=opaque\nsecret-password=Password"},
+	})
+	stderr.Reset()
+	result = CmdSecrets(entryMap, out, nil, "manifest", "{{.Group}}-{{.Title}}", false, false, "", &stdout, &stderr)
+	expected := "invalid name 'a/b-postgres' of entry '/a/b/postgres'\ninvalid name '-redis' of entry '/redis'\n"
+	if result == 0 || stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %d %s", result, stderr.String())
+	}
+
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("%s must not be written", out)
+	}
+}
+
+// docker secret aggregating several registries
+func TestSecretsDockerInclude(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/registries/a", "Title": "a", "URL": "a.example.com", "UserName": "user-a", "Password": "pass\"a", "Email": "a@example.com"},
+		{"path": "/registries/b", "Title": "b", "URL": "b.example.com", "UserName": "user-b", "Password": "pass-b", "Mail": "b@example.com",
+			"Notes": "secret-docker-email=Mail"},
+		{"path": "/pull-secret", "Title": "pull-secret",
+			"Notes": "secret-type=docker\nsecret-docker-include=/registries/*"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources, _ := collectResources(entryMap, nil, "", false, &stdout, &stderr)
+	if len(resources) != 1 {
+		t.Errorf("unexpected resources %d %s", len(resources), stderr.String())
+		return
+	}
+
+	expected := "{\"auths\":{" +
+		"\"a.example.com\":{\"username\":\"user-a\",\"password\":\"pass\\\"a\",\"email\":\"a@example.com\",\"auth\":\"dXNlci1hOnBhc3MiYQ==\"}," +
+		"\"b.example.com\":{\"username\":\"user-b\",\"password\":\"pass-b\",\"email\":\"b@example.com\",\"auth\":\"dXNlci1iOnBhc3MtYg==\"}}}"
+	actual, _ := resources[0].GetData(".dockerconfigjson")
+	if expected != string(actual) {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+
+	line := "secret docker name=pull-secret url=a.example.com,b.example.com username=user-a,user-b\n"
+	if stdout.String() != line {
+		t.Errorf("unexpected stdout: %s", stdout.String())
+	}
+}
+
+// legacy docker secret
+func TestSecretsDockerCfg(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources := make([]Resource, 0)
+	values := NewEntry()
+	values.SetValue("Title", "registry")
+	values.SetValue("URL", "https://registry.example.com")
+	values.SetValue("UserName", "foo")
+	values.SetValue("Password", "bar")
+	values.SetValue("Notes", "secret-type=docker\nsecret-docker-format=dockercfg")
+	createDockerSecret("e0", "", "", NewNotes(*values), *values, nil, &resources, &stdout, &stderr)
+
+	if len(resources) != 1 || resources[0].secretType != "kubernetes.io/dockercfg" {
+		t.Errorf("unexpected resources %v %s", resources, stderr.String())
+		return
+	}
+
+	expected := "{\"https://registry.example.com\":{\"username\":\"foo\",\"password\":\"bar\",\"auth\":\"Zm9vOmJhcg==\"}}"
+	actual, _ := resources[0].GetData(".dockercfg")
+	if expected != string(actual) {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+}
+
+// docker secret with include pattern not matching any entry
+func TestSecretsDockerIncludeNoMatch(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/pull-secret", "Title": "pull-secret",
+			"Notes": "secret-type=docker\nsecret-docker-include=/registries/*"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	collectResources(entryMap, nil, "", false, &stdout, &stderr)
+
+	expected := "include '/registries/*' of entry '/pull-secret' does not match any entry\nno registry found for entry '/pull-secret'\n"
+	actual := stderr.String()
+	if expected != actual {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+}
+
+// annotations defined as custom string fields, fields take precedence over notes
+// "secret-" fields other than reserved keys and "config-" fields are ordinary fields
+func TestSecretsFieldAnnotations(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "admin", "Password": "secret", "URL": "db.example.com",
+			"Notes":         "comment\nsecret-type=opaque\nsecret-user=UserName\nsecret-namespace=dev",
+			"k8s.namespace": "prod", "k8s.password": "Password", "k8s.config-host": "URL",
+			"secret-key": "AKIAEXAMPLE", "config-file": "a: 1"},
+		{"path": "/plain", "Title": "plain", "secret-key": "AKIAEXAMPLE", "config-file": "a: 1"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources, _ := collectResources(entryMap, nil, "", false, &stdout, &stderr)
+	if len(resources) != 2 || stderr.String() != "" {
+		t.Errorf("unexpected resources %d %s", len(resources), stderr.String())
+		return
+	}
+
+	if resources[0].namespace != "prod" || strings.Join(resources[0].GetKeys(), ",") != "user,password" {
+		t.Errorf("unexpected secret %s %v", resources[0].namespace, resources[0].GetKeys())
+	}
+
+	if host, _ := resources[1].GetData("host"); resources[1].kind != "ConfigMap" || string(host) != "db.example.com" {
+		t.Errorf("unexpected ConfigMap %s %s", resources[1].kind, host)
+	}
+}
+
+// strict mode aborts on warnings without writing the output file
+func TestSecretsStrict(t *testing.T) {
+	out := "test/strict.yaml"
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/ok", "Title": "ok", "UserName": "admin", "Notes": "secret-type=opaque\nsecret-user=UserName"},
+		{"path": "/typo", "Title": "typo", "Password": "secret", "URL": "", "Notes": "secret-type=opaque\nsecret-password=Pasword\nsecret-url=URL"},
+		{"path": "/unknown", "Title": "unknown", "Notes": "secret-type=opaqe"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdSecrets(entryMap, out, nil, "manifest", "", true, false, "", &stdout, &stderr)
+
+	if result != 3 {
+		t.Errorf("strict mode must fail with 3, result=%d", result)
+	}
+
+	expected := "entry '/typo' does not contain the field of key 'password'\n" +
+		"entry '/typo' contains empty value for key 'url'\n" +
+		"unknown type 'opaqe' of entry '/unknown', ignored\n" +
+		"3 warning(s) in strict mode, output not written\n"
+	if stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stderr.String())
+	}
+
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("%s must not be written", out)
+		testDeleteFile(out, t)
+	}
+
+	// lenient mode (default) writes the partial output
+	stderr = strings.Builder{}
+	if result = CmdSecrets(entryMap, out, nil, "manifest", "", false, false, "", &stdout, &stderr); result != 0 {
+		t.Errorf("lenient mode must succeed, result=%d", result)
+	}
+
+	testDeleteFile(out, t)
+}
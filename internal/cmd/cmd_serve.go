@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tobischo/gokeepasslib/v3"
+)
+
+const secretsPrefix = "/secrets/"
+
+// HTTP server providing the entries of a KeePass database
+// the API is compatible with the webhook provider of the External Secrets Operator:
+// GET /secrets/<path>/<field> returns {"value": "<field value>"}
+// the database is loaded once and reloaded when the file has been modified
+type Server struct {
+	db       string
+	pw       string
+	keyFile  string
+	token    string
+	resolve  bool // resolve references and placeholders
+	mutex    sync.Mutex
+	modTime  time.Time
+	size     int64
+	entryMap *EntryMap
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// create server and load database
+func NewServer(db string, pw string, keyFile string, token string, resolve bool, stdout io.Writer, stderr io.Writer) (*Server, error) {
+	server := Server{db: db, pw: pw, keyFile: keyFile, token: token, resolve: resolve, stdout: stdout, stderr: stderr}
+	if err := server.reload(); err != nil {
+		return nil, err
+	}
+
+	return &server, nil
+}
+
+// reload database if modification time or size of the file has changed
+// caller must hold the mutex
+func (server *Server) reload() error {
+	info, err := os.Stat(server.db)
+	if err != nil {
+		return err
+	}
+
+	if server.entryMap != nil && info.ModTime().Equal(server.modTime) && info.Size() == server.size {
+		return nil // not modified
+	}
+
+	db, err := openDatabase(server.db, server.pw, server.keyFile)
+	if err != nil {
+		return err
+	}
+
+	if server.entryMap != nil {
+		fmt.Fprintf(server.stdout, "%s reloaded\n", server.db)
+	}
+
+	server.entryMap = loadEntryMap([]*gokeepasslib.Database{db}, []string{server.db}, server.resolve, server.stderr)
+	server.modTime = info.ModTime()
+	server.size = info.Size()
+	return nil
+}
+
+// returns current entry map, reloads database if necessary
+// on reload errors the previous entry map is kept
+func (server *Server) getEntryMap() *EntryMap {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if err := server.reload(); err != nil {
+		fmt.Fprintf(server.stderr, "cannot reload %s: %s\n", server.db, err)
+	}
+
+	return server.entryMap
+}
+
+// check bearer token of request
+func (server *Server) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(server.token)) == 1
+}
+
+// write JSON response and log request to stdout
+func (server *Server) respond(w http.ResponseWriter, r *http.Request, status int, body map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	fmt.Fprintf(server.stdout, "%s %s %d\n", r.Method, r.URL.Path, status)
+}
+
+// handle GET /secrets/<path>/<field>
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		server.respond(w, r, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	if !server.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		server.respond(w, r, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, secretsPrefix) {
+		server.respond(w, r, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	// split into entry path and field name
+	pathAndField := strings.TrimPrefix(r.URL.Path, secretsPrefix)
+	pos := strings.LastIndex(pathAndField, "/")
+	if pos < 1 || pos == len(pathAndField)-1 {
+		server.respond(w, r, http.StatusBadRequest, map[string]string{"error": "expected /secrets/<path>/<field>"})
+		return
+	}
+
+	path := "/" + pathAndField[:pos]
+	field := pathAndField[pos+1:]
+
+	values, ok := server.getEntryMap().GetValues(path)
+	if !ok {
+		server.respond(w, r, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("path '%s' does not exist", path)})
+		return
+	}
+
+	value, ok := values.GetValue(field)
+	if !ok {
+		server.respond(w, r, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("field '%s' does not exist in path '%s'", field, path)})
+		return
+	}
+
+	server.respond(w, r, http.StatusOK, map[string]string{"value": value})
+}
+
+// serve entries via HTTP(S) until the server fails
+func CmdServe(db string, pw string, keyFile string, listen string, token string, tlsCert string, tlsKey string, resolve bool, stdout io.Writer, stderr io.Writer) int {
+	server, err := NewServer(db, pw, keyFile, token, resolve, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+
+	httpServer := &http.Server{
+		Addr:              listen,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Fprintf(stdout, "serving %s on %s\n", db, listen)
+
+	if tlsCert != "" {
+		err = httpServer.ListenAndServeTLS(tlsCert, tlsKey)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+
+	fmt.Fprintf(stderr, "%s\n", err)
+	return 1
+}
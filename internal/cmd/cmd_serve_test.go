@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// send GET request with bearer token and decode JSON response
+func testServeGet(url string, token string, t *testing.T) (int, map[string]string) {
+	request, _ := http.NewRequest(http.MethodGet, url, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Errorf("request failed %s", err)
+		return 0, nil
+	}
+	defer response.Body.Close()
+
+	body := make(map[string]string)
+	json.NewDecoder(response.Body).Decode(&body)
+	return response.StatusCode, body
+}
+
+// get values via HTTP
+func TestServe(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	server, err := NewServer("test/test.kdbx", "1234", "", "abc", true, &stdout, &stderr)
+	if err != nil {
+		t.Errorf("cannot create server %s", err)
+		return
+	}
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	status, body := testServeGet(httpServer.URL+"/secrets/entry-1/Password", "abc", t)
+	if status != http.StatusOK || body["value"] != "abcd" {
+		t.Errorf("unexpected response %d %v", status, body)
+	}
+
+	status, body = testServeGet(httpServer.URL+"/secrets/folder-b/entry-b1/UserName", "abc", t)
+	if status != http.StatusOK || body["value"] != "admin-b1" {
+		t.Errorf("unexpected response %d %v", status, body)
+	}
+
+	status, body = testServeGet(httpServer.URL+"/secrets/invalid/Password", "abc", t)
+	if status != http.StatusNotFound || body["error"] != "path '/invalid' does not exist" {
+		t.Errorf("unexpected response %d %v", status, body)
+	}
+
+	status, body = testServeGet(httpServer.URL+"/secrets/entry-1/Invalid", "abc", t)
+	if status != http.StatusNotFound || body["error"] != "field 'Invalid' does not exist in path '/entry-1'" {
+		t.Errorf("unexpected response %d %v", status, body)
+	}
+
+	status, _ = testServeGet(httpServer.URL+"/secrets/entry-1", "abc", t)
+	if status != http.StatusBadRequest {
+		t.Errorf("unexpected status %d", status)
+	}
+
+	status, _ = testServeGet(httpServer.URL+"/secrets/entry-1/Password", "", t)
+	if status != http.StatusUnauthorized {
+		t.Errorf("unexpected status %d", status)
+	}
+
+	status, _ = testServeGet(httpServer.URL+"/secrets/entry-1/Password", "abd", t)
+	if status != http.StatusUnauthorized {
+		t.Errorf("unexpected status %d", status)
+	}
+
+	if strings.Contains(stdout.String(), "abcd") {
+		t.Errorf("stdout must not contain values: %s", stdout.String())
+	}
+
+	if !strings.Contains(stdout.String(), "GET /secrets/entry-1/Password 200") {
+		t.Errorf("stdout missing line: %s", stdout.String())
+	}
+}
+
+// database is reloaded after modification
+func TestServeReload(t *testing.T) {
+	db := "test/serve.kdbx"
+	pw := "a1b2c3d4"
+
+	if !testCreateDatabase(db, pw, t) {
+		return
+	}
+
+	if !testFillDatabase(db, pw, t) {
+		return
+	}
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	server, err := NewServer(db, pw, "", "abc", true, &stdout, &stderr)
+	if err != nil {
+		t.Errorf("cannot create server %s", err)
+		return
+	}
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	status, body := testServeGet(httpServer.URL+"/secrets/1/A/Password", "abc", t)
+	if status != http.StatusOK || body["value"] != "secret0" {
+		t.Errorf("unexpected response %d %v", status, body)
+	}
+
+	args := []string{"set", "-d", db, "-p", pw, "-e", "/1/A", "-f", "UserName=admin", "-f", "Password=secret2"}
+	if result := Run(args, &strings.Builder{}, &strings.Builder{}); result != 0 {
+		t.Errorf("set failed, result=%d", result)
+		return
+	}
+
+	status, body = testServeGet(httpServer.URL+"/secrets/1/A/Password", "abc", t)
+	if status != http.StatusOK || body["value"] != "secret2" {
+		t.Errorf("unexpected response %d %v", status, body)
+	}
+
+	if !strings.Contains(stdout.String(), db+" reloaded") {
+		t.Errorf("stdout missing line: %s", stdout.String())
+	}
+
+	testDeleteFile(db, t)
+}
+
+// serve, database file is missing
+func TestServeInvalidDatabase(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	args := []string{"serve", "-d", "test/noexist.kdbx", "-p", "1234", "--token", "abc"}
+	result := Run(args, &stdout, &stderr)
+	if result == 0 {
+		t.Errorf("run must fail")
+	}
+}
@@ -6,6 +6,18 @@ import (
 	"testing"
 )
 
+// isolate tests from the config file and the environment of the user
+func TestMain(m *testing.M) {
+	dir, _ := os.MkdirTemp("", "keepass-secret-test")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	os.Unsetenv("KSPASSWORD")
+	os.Unsetenv("KSTOKEN")
+
+	result := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(result)
+}
+
 // read file into string
 func readFile(file string, t *testing.T) string {
 	if _, err := os.Stat(file); os.IsNotExist(err) {
@@ -180,3 +192,25 @@ func testDeleteFile(file string, t *testing.T) bool {
 
 	return true
 }
+
+// create entry map from list of field maps, the "path" field defines the path of the entry
+// the "Tags" field defines the native tags
+func testNewEntryMap(list []map[string]string) *EntryMap {
+	entryMap := EntryMap{paths: make([]string, 0), entries: make(map[string]Entry), uuids: make(map[string]string)}
+	for i := 0; i < len(list); i++ {
+		values := NewEntry()
+		for name, value := range list[i] {
+			if name == "Tags" {
+				values.SetTags(splitTags(value))
+			} else if name != "path" {
+				values.SetValue(name, value)
+			}
+		}
+
+		path := list[i]["path"]
+		entryMap.entries[path] = *values
+		entryMap.paths = append(entryMap.paths, path)
+	}
+
+	return &entryMap
+}
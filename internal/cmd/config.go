@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configFile = ".keepass-secret.yaml" // config file in the working directory
+
+// config file with named profiles of default options
+//
+//	default-profile: dev
+//	profiles:
+//	  dev:
+//	    database: [base.kdbx, dev.kdbx]
+//	    password-command: pass show keepass/dev
+//	    tags: dev
+type Config struct {
+	DefaultProfile string             `yaml:"default-profile"` // used without --profile
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// default options of a profile, relative database and key files are relative to the config file
+type Profile struct {
+	Databases       stringList `yaml:"database"`
+	KeyFiles        stringList `yaml:"key-file"`         // one per database or one for all
+	PasswordCommand string     `yaml:"password-command"` // executed by "sh -c", stdout is the password
+	Tags            stringList `yaml:"tags"`
+	TagSource       string     `yaml:"tag-source"`
+	Format          string     `yaml:"format"`
+	NameTemplate    string     `yaml:"name-template"`
+	Out             string     `yaml:"out"`
+}
+
+// single string or list of strings
+type stringList []string
+
+func (list *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*list = []string{node.Value}
+		return nil
+	}
+
+	values := make([]string, 0)
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+
+	*list = values
+	return nil
+}
+
+// returns the config file: option, .keepass-secret.yaml in the working directory
+// or $XDG_CONFIG_HOME/keepass-secret/config.yaml (default ~/.config), empty if none exists
+// the flag is true for the config file of the working directory (not trusted to run commands)
+func configPath(config string) (string, bool) {
+	if config != "" {
+		return config, false
+	}
+
+	if _, err := os.Stat(configFile); err == nil {
+		return configFile, true
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	file := filepath.Join(dir, "keepass-secret", "config.yaml")
+	if _, err := os.Stat(file); err == nil {
+		return file, false
+	}
+
+	return "", false
+}
+
+// load profile of config file, without name the default profile is used
+// returns nil if no profile is selected
+func loadProfile(file string, name string) (*Profile, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config %s", err)
+	}
+
+	config := Config{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse config %s: %s", file, err)
+	}
+
+	if name == "" {
+		name = config.DefaultProfile
+		if name == "" {
+			return nil, nil // no profile selected
+		}
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile '%s' not found in config %s", name, file)
+	}
+
+	dir := filepath.Dir(file)
+	for i := 0; i < len(profile.Databases); i++ {
+		profile.Databases[i] = resolveConfigPath(profile.Databases[i], dir)
+	}
+	for i := 0; i < len(profile.KeyFiles); i++ {
+		profile.KeyFiles[i] = resolveConfigPath(profile.KeyFiles[i], dir)
+	}
+
+	return &profile, nil
+}
+
+func resolveConfigPath(file string, dir string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+
+	return filepath.Join(dir, file)
+}
+
+// run password command of profile, returns stdout without trailing line break
+func runPasswordCommand(command string) (string, error) {
+	output, err := exec.Command("sh", "-c", command).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("password command failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("password command failed: %s", err)
+	}
+
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
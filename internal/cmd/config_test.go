@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// write config file with profiles to a temporary directory
+func testWriteConfig(t *testing.T) string {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	content := "default-profile: dev\n" +
+		"profiles:\n" +
+		"  dev:\n" +
+		"    database: [base.kdbx, /data/dev.kdbx]\n" +
+		"    password-command: echo 1234\n" +
+		"    tags: dev\n" +
+		"    format: helm\n" +
+		"    out: dev.yaml\n" +
+		"  prod:\n" +
+		"    database: prod.kdbx\n" +
+		"    key-file: prod.key\n" +
+		"    tags: [prod, '!legacy']\n" +
+		"    name-template: '{{.Title}}-prod'\n"
+	os.WriteFile(config, []byte(content), 0600)
+	return config
+}
+
+// default profile fills options which are not set on the commandline
+func TestConfigDefaultProfile(t *testing.T) {
+	config := testWriteConfig(t)
+	dir := filepath.Dir(config)
+
+	stderr := strings.Builder{}
+	options := NewOptions()
+	if !options.Parse([]string{"secrets", "--config", config}, &stderr) {
+		t.Errorf("parse failed %s", stderr.String())
+		return
+	}
+
+	actual := strings.Join(options.GetDbs(), ",") + " " + options.GetPw() + " " + strings.Join(options.GetTags(), ",") + " " + options.GetFormat() + " " + options.GetOut()
+	expected := filepath.Join(dir, "base.kdbx") + ",/data/dev.kdbx 1234 dev helm dev.yaml"
+	if actual != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+}
+
+// commandline options and environment take precedence over the profile
+func TestConfigPrecedence(t *testing.T) {
+	config := testWriteConfig(t)
+	t.Setenv("KSPASSWORD", "env")
+
+	stderr := strings.Builder{}
+	options := NewOptions()
+	args := []string{"secrets", "--config", config, "--profile", "prod", "-d", "other.kdbx", "-t", "test", "--name-template", "", "-o", "out.yaml"}
+	if !options.Parse(args, &stderr) {
+		t.Errorf("parse failed %s", stderr.String())
+		return
+	}
+
+	actual := options.GetDb() + " " + options.GetPw() + " " + strings.Join(options.GetTags(), ",") + " " + options.GetNameTemplate() + " " + options.GetOut()
+	expected := "other.kdbx env test  out.yaml"
+	if actual != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+
+	// key file of the profile belongs to the database of the profile
+	if options.GetKeyFile() != "" {
+		t.Errorf("unexpected key file %s", options.GetKeyFile())
+	}
+}
+
+// key files and password command are only used with the databases of the profile
+func TestConfigDatabaseOption(t *testing.T) {
+	config := testWriteConfig(t)
+	os.WriteFile(config, []byte("default-profile: dev\nprofiles:\n  dev:\n    database: [a.kdbx, b.kdbx]\n    key-file: [a.key, b.key]\n    password-command: echo 1234\n"), 0600)
+
+	stderr := strings.Builder{}
+	options := NewOptions()
+	if !options.Parse([]string{"list", "--config", config}, &stderr) || options.GetPw() != "1234" || len(options.GetKeyFiles()) != 2 {
+		t.Errorf("parse with profile databases failed %s", stderr.String())
+	}
+
+	stderr = strings.Builder{}
+	options = NewOptions()
+	if options.Parse([]string{"list", "--config", config, "-d", "other.kdbx"}, &stderr) {
+		t.Errorf("password command must not be used with -d")
+	}
+
+	if expected := "missing -p/--password parameter\n"; stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stderr.String())
+	}
+
+	stderr = strings.Builder{}
+	options = NewOptions()
+	if !options.Parse([]string{"list", "--config", config, "-d", "other.kdbx", "-p", "x"}, &stderr) || options.GetKeyFiles()[0] != "" {
+		t.Errorf("key files of the profile must not be used with -d %v %s", options.GetKeyFiles(), stderr.String())
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	config := testWriteConfig(t)
+
+	stderr := strings.Builder{}
+	options := NewOptions()
+	if options.Parse([]string{"list", "--config", config, "--profile", "test"}, &stderr) {
+		t.Errorf("unknown profile must fail")
+	}
+
+	expected := "profile 'test' not found in config " + config + "\n"
+	if stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stderr.String())
+	}
+
+	os.WriteFile(config, []byte("default-profile: dev\nprofiles:\n  dev:\n    database: test.kdbx\n    password-command: exit 1\n"), 0600)
+	stderr = strings.Builder{}
+	options = NewOptions()
+	if options.Parse([]string{"list", "--config", config}, &stderr) || !strings.HasPrefix(stderr.String(), "password command failed") {
+		t.Errorf("failed password command must fail %s", stderr.String())
+	}
+}
+
+// config file of the working directory is used without --config, but does not run the password command
+func TestConfigWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.WriteFile(configFile, []byte("default-profile: dev\nprofiles:\n  dev:\n    database: test.kdbx\n    password-command: echo 1234\n"), 0600)
+
+	stderr := strings.Builder{}
+	options := NewOptions()
+	if !options.Parse([]string{"list", "-p", "abc"}, &stderr) {
+		t.Errorf("parse failed %s", stderr.String())
+		return
+	}
+
+	expected := "password-command of .keepass-secret.yaml ignored, use --config .keepass-secret.yaml to run it\n"
+	if stderr.String() != expected || options.GetDb() != "test.kdbx" || options.GetPw() != "abc" {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s %s %s", stderr.String(), options.GetDb(), options.GetPw())
+	}
+}
+
+// init does not use the database of the profile
+func TestConfigInit(t *testing.T) {
+	config := testWriteConfig(t)
+
+	stderr := strings.Builder{}
+	options := NewOptions()
+	if options.Parse([]string{"init", "--config", config, "-p", "1234"}, &stderr) {
+		t.Errorf("init without -d must fail")
+	}
+
+	if expected := "missing -d/--database parameter\n"; stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stderr.String())
+	}
+}
+
+// database protected by password and key file
+func TestKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "key.kdbx")
+	keyFile := filepath.Join(dir, "db.key")
+	os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0600)
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	if result := Run([]string{"init", "-d", db, "-p", "1234", "-k", keyFile}, &stdout, &stderr); result != 0 {
+		t.Errorf("init failed %d %s", result, stderr.String())
+	}
+
+	if result := Run([]string{"set", "-d", db, "-p", "1234", "-k", keyFile, "-e", "/db", "-f", "UserName=admin"}, &stdout, &stderr); result != 0 {
+		t.Errorf("set failed %d %s", result, stderr.String())
+	}
+
+	stdout = strings.Builder{}
+	if result := Run([]string{"get", "-d", db, "-p", "1234", "-k", keyFile, "-e", "/db", "-f", "UserName"}, &stdout, &stderr); result != 0 || stdout.String() != "admin" {
+		t.Errorf("get failed %d %s %s", result, stdout.String(), stderr.String())
+	}
+
+	stderr = strings.Builder{}
+	if result := Run([]string{"get", "-d", db, "-p", "1234", "-e", "/db", "-f", "UserName"}, &stdout, &stderr); result != 1 {
+		t.Errorf("get without key file must fail %d", result)
+	}
+}
+
+// layered databases with a key file for the base database only
+func TestKeyFileLayers(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.kdbx")
+	prod := filepath.Join(dir, "prod.kdbx")
+	keyFile := filepath.Join(dir, "base.key")
+	os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0600)
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	if result := Run([]string{"init", "-d", base, "-p", "1234", "-k", keyFile}, &stdout, &stderr); result != 0 {
+		t.Errorf("init failed %d %s", result, stderr.String())
+	}
+	if result := Run([]string{"set", "-d", base, "-p", "1234", "-k", keyFile, "-e", "/db", "-f", "UserName=admin"}, &stdout, &stderr); result != 0 {
+		t.Errorf("set failed %d %s", result, stderr.String())
+	}
+	testCreateDatabase(prod, "5678", t)
+	if result := Run([]string{"set", "-d", prod, "-p", "5678", "-e", "/db", "-f", "Password=prod"}, &stdout, &stderr); result != 0 {
+		t.Errorf("set failed %d %s", result, stderr.String())
+	}
+
+	stdout = strings.Builder{}
+	args := []string{"get", "-d", base, "-p", "1234", "-k", keyFile, "-d", prod, "-p", "5678", "-k", "", "-e", "/db", "-f", "UserName"}
+	if result := Run(args, &stdout, &stderr); result != 0 || stdout.String() != "admin" {
+		t.Errorf("get failed %d %s %s", result, stdout.String(), stderr.String())
+	}
+
+	stderr = strings.Builder{}
+	args = []string{"get", "-d", base, "-d", prod, "-p", "1234", "-p", "5678", "-k", keyFile, "-k", "", "-k", "", "-e", "/db", "-f", "UserName"}
+	if result := Run(args, &stdout, &stderr); result != 1 || !strings.HasPrefix(stderr.String(), "number of -k/--key-file parameters") {
+		t.Errorf("unexpected result %d %s", result, stderr.String())
+	}
+}
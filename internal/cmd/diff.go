@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Secret or ConfigMap read from a manifest file
+type manifestObject struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Immutable  bool              `yaml:"immutable"`
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// read all Secrets and ConfigMaps of a multi-document manifest file (other documents are ignored)
+func readManifest(file string) ([]Resource, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]Resource, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		object := manifestObject{}
+		if err := decoder.Decode(&object); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %s", file, err)
+		}
+
+		if object.Kind != "Secret" && object.Kind != "ConfigMap" {
+			continue
+		}
+
+		resource := NewResource(object.Kind, object.Metadata.Name, object.Metadata.Namespace, object.Type)
+		resource.immutable = object.Immutable
+		keys := sortedKeys(object.Data)
+		for i := 0; i < len(keys); i++ {
+			value := []byte(object.Data[keys[i]])
+			if resource.IsSecret() {
+				if value, err = base64.StdEncoding.DecodeString(object.Data[keys[i]]); err != nil {
+					return nil, fmt.Errorf("invalid base64 value of key '%s' of %s in %s", keys[i], resource.ref(), file)
+				}
+			}
+			resource.SetData(keys[i], value)
+		}
+
+		keys = sortedKeys(object.StringData)
+		for i := 0; i < len(keys); i++ {
+			resource.SetData(keys[i], []byte(object.StringData[keys[i]]))
+		}
+
+		resources = append(resources, *resource)
+	}
+
+	return resources, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// returns lowercase kind, namespace and name e.g. "secret dev/db"
+func (resource *Resource) ref() string {
+	if resource.namespace == "" {
+		return strings.ToLower(resource.kind) + " " + resource.name
+	}
+
+	return strings.ToLower(resource.kind) + " " + resource.namespace + "/" + resource.name
+}
+
+// random key of the masked values, equal values are masked identically within a run
+// but the masks cannot be used to guess values offline (e.g. from CI logs)
+var maskKey = rand.Text()
+
+// returns short HMAC-SHA256 of a value with the key of the run, values are never printed
+func maskValue(value []byte) string {
+	mac := hmac.New(sha256.New, []byte(maskKey))
+	mac.Write(value)
+	return fmt.Sprintf("hmac:%x", mac.Sum(nil)[:5])
+}
+
+// compare resources with the manifest file and print added, removed and changed resources and keys
+// returns 2 if there are differences
+func diffManifest(resources []Resource, file string, stdout io.Writer, stderr io.Writer) int {
+	previous, err := readManifest(file)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+
+	if differences := diffResources(previous, resources, stdout); differences > 0 {
+		fmt.Fprintf(stderr, "%d difference(s) to %s\n", differences, file)
+		return 2 // differences found
+	}
+
+	return 0 // success
+}
+
+// print added, removed and changed resources and keys, returns the number of different resources
+func diffResources(previous []Resource, resources []Resource, stdout io.Writer) int {
+	previousMap := make(map[string]*Resource)
+	for i := 0; i < len(previous); i++ {
+		previousMap[previous[i].ref()] = &previous[i]
+	}
+
+	differences := 0
+	current := make(map[string]bool)
+	for i := 0; i < len(resources); i++ {
+		resource := &resources[i]
+		current[resource.ref()] = true
+		old, ok := previousMap[resource.ref()]
+		if !ok {
+			fmt.Fprintf(stdout, "added %s\n", resource.ref())
+			for j := 0; j < len(resource.keys); j++ {
+				fmt.Fprintf(stdout, "  added key %s (%s)\n", resource.keys[j], maskValue(resource.data[resource.keys[j]]))
+			}
+			differences++
+			continue
+		}
+
+		changes := diffResource(old, resource)
+		if len(changes) > 0 {
+			fmt.Fprintf(stdout, "changed %s\n", resource.ref())
+			for j := 0; j < len(changes); j++ {
+				fmt.Fprintf(stdout, "  %s\n", changes[j])
+			}
+			differences++
+		}
+	}
+
+	for i := 0; i < len(previous); i++ {
+		if !current[previous[i].ref()] {
+			fmt.Fprintf(stdout, "removed %s\n", previous[i].ref())
+			differences++
+		}
+	}
+
+	return differences
+}
+
+// returns the changes of type, immutable and keys (values are masked)
+func diffResource(old *Resource, resource *Resource) []string {
+	changes := make([]string, 0)
+	if old.secretType != resource.secretType {
+		changes = append(changes, fmt.Sprintf("changed type %s -> %s", old.secretType, resource.secretType))
+	}
+
+	if old.immutable != resource.immutable {
+		changes = append(changes, fmt.Sprintf("changed immutable %t -> %t", old.immutable, resource.immutable))
+	}
+
+	for i := 0; i < len(resource.keys); i++ {
+		key := resource.keys[i]
+		value := resource.data[key]
+		if oldValue, ok := old.data[key]; !ok {
+			changes = append(changes, fmt.Sprintf("added key %s (%s)", key, maskValue(value)))
+		} else if !bytes.Equal(oldValue, value) {
+			changes = append(changes, fmt.Sprintf("changed key %s (%s -> %s)", key, maskValue(oldValue), maskValue(value)))
+		}
+	}
+
+	for i := 0; i < len(old.keys); i++ {
+		if _, ok := resource.data[old.keys[i]]; !ok {
+			changes = append(changes, fmt.Sprintf("removed key %s (%s)", old.keys[i], maskValue(old.data[old.keys[i]])))
+		}
+	}
+
+	return changes
+}
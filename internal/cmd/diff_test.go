@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unchanged database has no differences, changes are reported with masked values
+func TestSecretsDiffAgainst(t *testing.T) {
+	old := filepath.Join(t.TempDir(), "old.yaml")
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "admin", "Password": "secret", "Notes": "secret-type=opaque\nsecret-user=UserName\nsecret-password=Password"},
+		{"path": "/app", "Title": "app", "URL": "app.example.com", "Notes": "secret-type=configmap\nsecret-host=URL"},
+		{"path": "/old", "Title": "old", "Password": "old", "Notes": "secret-type=opaque\nsecret-namespace=dev\nsecret-password=Password"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	if result := CmdSecrets(entryMap, old, nil, "manifest", "", false, true, "", &stdout, &stderr); result != 0 {
+		t.Errorf("cannot write manifest %d %s", result, stderr.String())
+		return
+	}
+
+	stdout = strings.Builder{}
+	if result := CmdSecrets(entryMap, "", nil, "manifest", "", false, false, old, &stdout, &stderr); result != 0 {
+		t.Errorf("unexpected differences %d %s", result, stdout.String())
+	}
+
+	entryMap = testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "admin", "Password": "changed", "URL": "db", "Notes": "secret-type=opaque\nsecret-password=Password\nsecret-url=URL\nsecret-immutable=true"},
+		{"path": "/app", "Title": "app", "URL": "app.example.com", "Notes": "secret-type=configmap\nsecret-host=URL"},
+		{"path": "/new", "Title": "new", "Password": "new", "Notes": "secret-type=opaque\nsecret-password=Password"},
+	})
+
+	stdout = strings.Builder{}
+	stderr = strings.Builder{}
+	result := CmdSecrets(entryMap, "", nil, "manifest", "", false, false, old, &stdout, &stderr)
+	if result != 2 {
+		t.Errorf("differences must return 2, result=%d", result)
+	}
+
+	expected := "secret opaque name=db fields=password,url\n" +
+		"configmap name=app fields=host\n" +
+		"secret opaque name=new fields=password\n" +
+		"changed secret db\n" +
+		"  changed immutable false -> true\n" +
+		"  changed key password (" + maskValue([]byte("secret")) + " -> " + maskValue([]byte("changed")) + ")\n" +
+		"  added key url (" + maskValue([]byte("db")) + ")\n" +
+		"  removed key user (" + maskValue([]byte("admin")) + ")\n" +
+		"added secret new\n" +
+		"  added key password (" + maskValue([]byte("new")) + ")\n" +
+		"removed secret dev/old\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+
+	if stderr.String() != "3 difference(s) to "+old+"\n" {
+		t.Errorf("unexpected stderr %s", stderr.String())
+	}
+}
+
+func TestSecretsDiffAgainstInvalid(t *testing.T) {
+	old := filepath.Join(t.TempDir(), "old.yaml")
+	os.WriteFile(old, []byte("kind: Secret\nmetadata:\n  name: db\ndata:\n  password: \"%%%\"\n"), 0600)
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	result := CmdSecrets(testNewEntryMap(nil), "", nil, "manifest", "", false, false, old, &stdout, &stderr)
+	expected := "invalid base64 value of key 'password' of secret db in " + old + "\n"
+	if result != 1 || stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %d %s", result, stderr.String())
+	}
+}
+
+// masks are keyed per run: equal values are masked identically, but not by their plain hash
+func TestMaskValue(t *testing.T) {
+	plain := fmt.Sprintf("hmac:%x", sha256.Sum256([]byte("secret")))[:15]
+	if maskValue([]byte("secret")) != maskValue([]byte("secret")) || maskValue([]byte("secret")) == plain {
+		t.Errorf("unexpected mask %s", maskValue([]byte("secret")))
+	}
+
+	if maskValue([]byte("secret")) == maskValue([]byte("other")) {
+		t.Errorf("different values must have different masks")
+	}
+}
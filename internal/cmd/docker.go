@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	pathpkg "path"
+	"strings"
+)
+
+// credentials of a single docker registry
+type dockerAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth"`
+}
+
+// models the content of a docker config (one or more registries)
+type DockerConfig struct {
+	urls  []string // registry URLs in order of definition
+	auths map[string]dockerAuth
+}
+
+func NewDockerConfig() *DockerConfig {
+	return &DockerConfig{urls: make([]string, 0), auths: make(map[string]dockerAuth)}
+}
+
+// add registry defined by URL, UserName, Password and email field of entry
+// the email field is "Email" unless specified by "secret-docker-email"
+// returns false if a mandatory field is missing
+func (config *DockerConfig) Add(path string, notes *Notes, values Entry, stderr io.Writer) bool {
+	username, _ := values.GetValue("UserName")
+	if username == "" {
+		fmt.Fprintf(stderr, "missing UserName for entry '%s'\n", path)
+		return false
+	}
+
+	password, _ := values.GetValue("Password")
+	if password == "" {
+		fmt.Fprintf(stderr, "missing Password for entry '%s'\n", path)
+		return false
+	}
+
+	url, _ := values.GetValue("URL")
+	if url == "" {
+		fmt.Fprintf(stderr, "missing URL for entry '%s'\n", path)
+		return false
+	}
+
+	emailField := notes.Get("docker-email")
+	email, ok := values.GetValue(emailField)
+	if emailField == "" {
+		email, _ = values.GetValue("Email")
+	} else if !ok {
+		fmt.Fprintf(stderr, "entry '%s' does not contain the field of secret-docker-email\n", path)
+		return false
+	}
+
+	if _, exists := config.auths[url]; exists {
+		fmt.Fprintf(stderr, "registry '%s' of entry '%s' is already defined, ignored\n", url, path)
+		return true
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	config.auths[url] = dockerAuth{Username: username, Password: password, Email: email, Auth: auth}
+	config.urls = append(config.urls, url)
+	return true
+}
+
+// add registries of all entries matching one of the path patterns (e.g. /registries/*)
+func (config *DockerConfig) Include(path string, patterns []string, entryMap *EntryMap, stderr io.Writer) {
+	paths := entryMap.GetPaths()
+	for i := 0; i < len(patterns); i++ {
+		pattern := strings.TrimSpace(patterns[i])
+		matched := false
+		for j := 0; j < len(paths); j++ {
+			if ok, _ := pathpkg.Match(pattern, paths[j]); !ok || paths[j] == path {
+				continue
+			}
+
+			matched = true
+			values, _ := entryMap.GetValues(paths[j])
+			config.Add(paths[j], NewNotes(values), values, stderr)
+		}
+
+		if !matched {
+			fmt.Fprintf(stderr, "include '%s' of entry '%s' does not match any entry\n", pattern, path)
+		}
+	}
+}
+
+// returns user names of all registries
+func (config *DockerConfig) GetUserNames() []string {
+	usernames := make([]string, 0)
+	for i := 0; i < len(config.urls); i++ {
+		usernames = append(usernames, config.auths[config.urls[i]].Username)
+	}
+
+	return usernames
+}
+
+// returns content of .dockerconfigjson
+func (config *DockerConfig) MarshalDockerConfigJson() []byte {
+	return marshalJson(map[string]map[string]dockerAuth{"auths": config.auths})
+}
+
+// returns content of legacy .dockercfg
+func (config *DockerConfig) MarshalDockerCfg() []byte {
+	return marshalJson(config.auths)
+}
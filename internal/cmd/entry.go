@@ -3,6 +3,7 @@ package cmd
 type Entry struct {
 	values   map[string]string
 	binaries map[string][]byte
+	tags     []string // native KeePass tags
 }
 
 func NewEntry() *Entry {
@@ -27,6 +28,14 @@ func (entry *Entry) GetBinary(name string) ([]byte, bool) {
 	return value, ok
 }
 
+func (entry *Entry) SetTags(tags []string) {
+	entry.tags = tags
+}
+
+func (entry *Entry) GetTags() []string {
+	return entry.tags
+}
+
 func (entry *Entry) GetNames() []string {
 	names := make([]string, 0, len(entry.values))
 	for name := range entry.values {
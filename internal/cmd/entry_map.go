@@ -1,14 +1,23 @@
 package cmd
 
 import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
 	"github.com/tobischo/gokeepasslib/v3"
 )
 
 // model complete KeePass database as flat list of entries
 // each entry is defined by its path an a key/value map of the entry fields
 type EntryMap struct {
-	paths   []string         // all full qualified paths (used for iteration)
-	entries map[string]Entry // map path to entry (key/value map)
+	paths    []string                     // all full qualified paths (used for iteration)
+	entries  map[string]Entry             // map path to entry (key/value map)
+	uuids    map[string]string            // map path to UUID of entry (upper case hex, used by references)
+	layers   map[string]map[string]string // map path to field/attachment name to database file (layered databases only)
+	warnings int                          // number of warnings while resolving references (counted by strict mode)
 }
 
 // recursively process group (folder of entries) and store entries in map
@@ -37,8 +46,11 @@ func (entryMap *EntryMap) processGroup(group *gokeepasslib.Group, path string, r
 			}
 		}
 
+		values.SetTags(splitTags(entry.Tags))
+
 		key := path + entry.GetTitle()
 		entryMap.entries[key] = *values
+		entryMap.uuids[key] = strings.ToUpper(hex.EncodeToString(entry.UUID[:]))
 
 		entryMap.paths = append(entryMap.paths, key)
 	}
@@ -59,7 +71,7 @@ func (entryMap *EntryMap) GetValues(path string) (Entry, bool) {
 }
 
 func NewEntryMap(db *gokeepasslib.Database) *EntryMap {
-	entryMap := EntryMap{paths: make([]string, 0), entries: make(map[string]Entry)}
+	entryMap := EntryMap{paths: make([]string, 0), entries: make(map[string]Entry), uuids: make(map[string]string)}
 
 	root := &db.Content.Root.Groups[0]
 	recycleBin := db.Content.Meta.RecycleBinUUID
@@ -68,3 +80,98 @@ func NewEntryMap(db *gokeepasslib.Database) *EntryMap {
 
 	return &entryMap
 }
+
+// merge entries of an overlay database by path
+// non-empty fields, attachments and tags override those of existing entries, new entries are appended
+// the database file of each value is recorded as its layer
+func (entryMap *EntryMap) Merge(overlay *EntryMap, layer string) {
+	for i := 0; i < len(overlay.paths); i++ {
+		path := overlay.paths[i]
+		values := overlay.entries[path]
+		entry, exists := entryMap.entries[path]
+		if !exists {
+			entry = *NewEntry()
+			entryMap.paths = append(entryMap.paths, path)
+			entryMap.uuids[path] = overlay.uuids[path]
+		}
+
+		names := values.GetNames()
+		for j := 0; j < len(names); j++ {
+			if value, _ := values.GetValue(names[j]); value != "" || !exists {
+				entry.SetValue(names[j], value)
+				entryMap.setLayer(path, names[j], layer)
+			}
+		}
+
+		names = values.GetBinaries()
+		for j := 0; j < len(names); j++ {
+			value, _ := values.GetBinary(names[j])
+			entry.SetBinary(names[j], value)
+			entryMap.setLayer(path, names[j], layer)
+		}
+
+		if len(values.GetTags()) > 0 {
+			entry.SetTags(values.GetTags())
+		}
+
+		entryMap.entries[path] = entry
+	}
+}
+
+func (entryMap *EntryMap) setLayer(path string, name string, layer string) {
+	if entryMap.layers == nil {
+		entryMap.layers = make(map[string]map[string]string)
+	}
+
+	if entryMap.layers[path] == nil {
+		entryMap.layers[path] = make(map[string]string)
+	}
+
+	entryMap.layers[path][name] = layer
+}
+
+// returns the number of warnings reported while loading the entries
+func (entryMap *EntryMap) GetWarnings() int {
+	return entryMap.warnings
+}
+
+// returns the database file of a field or attachment (empty if not layered)
+func (entryMap *EntryMap) GetLayer(path string, name string) string {
+	return entryMap.layers[path][name]
+}
+
+// print the database file of each field and attachment
+func (entryMap *EntryMap) ReportLayers(writer io.Writer) {
+	for i := 0; i < len(entryMap.paths); i++ {
+		path := entryMap.paths[i]
+		names := make([]string, 0, len(entryMap.layers[path]))
+		for name := range entryMap.layers[path] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for j := 0; j < len(names); j++ {
+			fmt.Fprintf(writer, "%s %s layer=%s\n", path, names[j], entryMap.layers[path][names[j]])
+		}
+	}
+}
+
+// create entry map of one or more databases (later databases override earlier ones)
+// references and placeholders are resolved after merging unless disabled
+func loadEntryMap(dbs []*gokeepasslib.Database, files []string, resolve bool, stderr io.Writer) *EntryMap {
+	entryMap := NewEntryMap(dbs[0])
+	if len(dbs) > 1 {
+		base := entryMap
+		entryMap = &EntryMap{paths: make([]string, 0), entries: make(map[string]Entry), uuids: make(map[string]string)}
+		entryMap.Merge(base, files[0])
+		for i := 1; i < len(dbs); i++ {
+			entryMap.Merge(NewEntryMap(dbs[i]), files[i])
+		}
+	}
+
+	if resolve {
+		entryMap.ResolveReferences(stderr)
+	}
+
+	return entryMap
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// later layers override non-empty fields by path and append new entries
+func TestEntryMapMerge(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "admin", "Password": "base", "Tags": "base"},
+		{"path": "/app", "Title": "app", "URL": "app.example.com"},
+	})
+
+	merged := &EntryMap{paths: make([]string, 0), entries: make(map[string]Entry), uuids: make(map[string]string)}
+	merged.Merge(entryMap, "base.kdbx")
+	merged.Merge(testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "", "Password": "prod", "PasswordProd": "prod2"},
+		{"path": "/new", "Title": "new", "Password": "new"},
+	}), "prod.kdbx")
+
+	if actual := strings.Join(merged.GetPaths(), ","); actual != "/db,/app,/new" {
+		t.Errorf("unexpected paths %s", actual)
+	}
+
+	values, _ := merged.GetValues("/db")
+	userName, _ := values.GetValue("UserName")
+	password, _ := values.GetValue("Password")
+	if userName != "admin" || password != "prod" || strings.Join(values.GetTags(), ",") != "base" {
+		t.Errorf("unexpected values %s %s %v", userName, password, values.GetTags())
+	}
+
+	if merged.GetLayer("/db", "UserName") != "base.kdbx" || merged.GetLayer("/db", "Password") != "prod.kdbx" || merged.GetLayer("/new", "Title") != "prod.kdbx" {
+		t.Errorf("unexpected layers %v", merged.layers)
+	}
+
+	// base entry map is not modified
+	values, _ = entryMap.GetValues("/db")
+	if password, _ = values.GetValue("Password"); password != "base" {
+		t.Errorf("base must not be modified %s", password)
+	}
+}
+
+// get value of layered databases with different passwords and report layers
+func TestLayeredDatabases(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.kdbx")
+	prod := filepath.Join(dir, "prod.kdbx")
+	testCreateDatabase(base, "1234", t)
+	testCreateDatabase(prod, "5678", t)
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	if result := Run([]string{"set", "-d", base, "-p", "1234", "-e", "/db", "-f", "UserName=admin", "-f", "Password=base"}, &stdout, &stderr); result != 0 {
+		t.Errorf("set failed %d %s", result, stderr.String())
+	}
+	if result := Run([]string{"set", "-d", prod, "-p", "5678", "-e", "/db", "-f", "Password=prod"}, &stdout, &stderr); result != 0 {
+		t.Errorf("set failed %d %s", result, stderr.String())
+	}
+
+	stdout = strings.Builder{}
+	stderr = strings.Builder{}
+	result := Run([]string{"get", "-d", base, "-p", "1234", "-d", prod, "-p", "5678", "-e", "/db", "-f", "Password", "--layers"}, &stdout, &stderr)
+	if result != 0 || stdout.String() != "prod" {
+		t.Errorf("unexpected result %d %s %s", result, stdout.String(), stderr.String())
+	}
+
+	if !strings.Contains(stderr.String(), "/db Password layer="+prod+"\n") || !strings.Contains(stderr.String(), "/db UserName layer="+base+"\n") {
+		t.Errorf("unexpected layer report %s", stderr.String())
+	}
+
+	stderr = strings.Builder{}
+	result = Run([]string{"set", "-d", base, "-d", prod, "-p", "1234", "-e", "/db", "-f", "Password=x"}, &stdout, &stderr)
+	if expected := "multiple -d/--database parameters are not supported by set command\n"; result != 1 || stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %d %s", result, stderr.String())
+	}
+
+	stderr = strings.Builder{}
+	result = Run([]string{"get", "-d", base, "-d", prod, "-p", "1234", "-p", "5678", "-p", "9", "-e", "/db", "-f", "Password"}, &stdout, &stderr)
+	if result != 1 || !strings.HasPrefix(stderr.String(), "number of -p/--password parameters") {
+		t.Errorf("unexpected result %d %s", result, stderr.String())
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// render Helm values fragment keyed by secret/ConfigMap name
+// resources with the same name in different namespaces are merged into
+// a single entry with a list of namespaces, namespaces with different data
+// (e.g. "secret-dev:password=PasswordDev") are written to "namespaceData"
+// returns false if resources with the same name differ in type or immutability
+func renderHelmValues(resources []Resource, stderr io.Writer) ([]string, bool) {
+	secrets, ok := mergeByName(resources, true, stderr)
+	configMaps, configOk := mergeByName(resources, false, stderr)
+	if !ok || !configOk {
+		return nil, false
+	}
+
+	lines := make([]string, 0)
+	if len(secrets) > 0 {
+		lines = append(lines, "secrets:")
+		for i := 0; i < len(secrets); i++ {
+			secrets[i].appendHelmValues(&lines)
+		}
+	}
+
+	if len(configMaps) > 0 {
+		lines = append(lines, "configMaps:")
+		for i := 0; i < len(configMaps); i++ {
+			configMaps[i].appendHelmValues(&lines)
+		}
+	}
+
+	return lines, true
+}
+
+// resource with all namespaces it is deployed to
+type namespacedResource struct {
+	resource      *Resource
+	namespaces    []string
+	namespaceData []*Resource // resources of namespaces whose data differs from resource
+}
+
+func (entry *namespacedResource) appendHelmValues(lines *[]string) {
+	resource := entry.resource
+	*lines = append(*lines, "  "+quote(resource.name)+":")
+	if resource.IsSecret() {
+		*lines = append(*lines, "    type: "+quote(resource.secretType))
+	}
+	if resource.immutable {
+		*lines = append(*lines, "    immutable: true")
+	}
+
+	if len(entry.namespaces) > 0 {
+		*lines = append(*lines, "    namespaces:")
+		for i := 0; i < len(entry.namespaces); i++ {
+			*lines = append(*lines, "    - "+quote(entry.namespaces[i]))
+		}
+	}
+
+	*lines = append(*lines, "    data:")
+	resource.appendHelmData(lines, "      ")
+
+	if len(entry.namespaceData) > 0 {
+		*lines = append(*lines, "    namespaceData:")
+		for i := 0; i < len(entry.namespaceData); i++ {
+			*lines = append(*lines, "      "+quote(entry.namespaceData[i].namespace)+":")
+			entry.namespaceData[i].appendHelmData(lines, "        ")
+		}
+	}
+}
+
+// append data values (base64 for secrets, plain text for ConfigMaps)
+func (resource *Resource) appendHelmData(lines *[]string, indent string) {
+	for i := 0; i < len(resource.keys); i++ {
+		key := resource.keys[i]
+		value := resource.data[key]
+		if resource.IsSecret() {
+			*lines = append(*lines, indent+quote(key)+": "+quote(base64.StdEncoding.EncodeToString(value)))
+		} else {
+			*lines = append(*lines, indent+quote(key)+": "+quote(string(value)))
+		}
+	}
+}
+
+// group Secrets (or ConfigMaps) by name and collect their namespaces
+func mergeByName(resources []Resource, secrets bool, stderr io.Writer) ([]namespacedResource, bool) {
+	ok := true
+	result := make([]namespacedResource, 0)
+	index := make(map[string]int)
+	for i := 0; i < len(resources); i++ {
+		resource := &resources[i]
+		if resource.IsSecret() != secrets {
+			continue
+		}
+
+		pos, exists := index[resource.name]
+		if !exists {
+			index[resource.name] = len(result)
+			result = append(result, namespacedResource{resource: resource, namespaces: make([]string, 0), namespaceData: make([]*Resource, 0)})
+			pos = len(result) - 1
+		} else if first := result[pos].resource; first.secretType != resource.secretType || first.immutable != resource.immutable || resource.namespace == "" {
+			fmt.Fprintf(stderr, "%s '%s' in namespace '%s' conflicts with previous definition\n", resource.kind, resource.name, resource.namespace)
+			ok = false
+			continue
+		} else if !sameData(first, resource) {
+			result[pos].namespaceData = append(result[pos].namespaceData, resource)
+		}
+
+		if resource.namespace != "" {
+			result[pos].namespaces = append(result[pos].namespaces, resource.namespace)
+		}
+	}
+
+	return result, ok
+}
+
+// check if two resources contain identical data
+func sameData(resource1 *Resource, resource2 *Resource) bool {
+	if resource1.secretType != resource2.secretType || len(resource1.keys) != len(resource2.keys) {
+		return false
+	}
+
+	for i := 0; i < len(resource1.keys); i++ {
+		key := resource1.keys[i]
+		value, ok := resource2.data[key]
+		if !ok || !bytes.Equal(value, resource1.data[key]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// keys of the keystores in TLS secrets
+var keystoreKeys = map[string]string{
+	"pkcs12": "keystore.p12",
+	"jks":    "keystore.jks",
+}
+
+// convert PEM certificate (UserName) and private key (Password) of entry to keystore (pkcs12 or jks)
+// the keystore password is taken from the field specified by "secret-keystore-password"
+// CA certificates ("secret-tls-ca") are added to the certificate chain
+func createKeystore(format string, alias string, notes *Notes, values Entry) ([]byte, error) {
+	passwordField := notes.Get("keystore-password")
+	if passwordField == "" {
+		return nil, errors.New("missing secret-keystore-password")
+	}
+
+	password, ok := values.GetValue(passwordField)
+	if !ok {
+		return nil, fmt.Errorf("missing value '%s'", passwordField)
+	}
+
+	crt, _ := values.GetValue("UserName")
+	certs, err := parseCertificates(crt)
+	if err != nil {
+		return nil, err
+	}
+
+	if field := notes.Get("tls-ca"); field != "" {
+		ca, _ := values.GetValue(field)
+		caCerts, err := parseCertificates(ca)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, caCerts...)
+	}
+
+	passphrase := ""
+	if field := notes.Get("tls-passphrase"); field != "" {
+		passphrase, _ = values.GetValue(field)
+	}
+
+	keyText, _ := values.GetValue("Password")
+	key, _, err := parsePrivateKey(keyText, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if !keyMatches(certs[0], key) {
+		return nil, errors.New("private key does not match certificate")
+	}
+
+	// salts and IVs are derived from the content, the same entry always produces the same keystore
+	// (stable name hash, no changes reported by --diff-against and drift)
+	rand := newDeterministicReader(format, alias, password, keyText, certs)
+
+	switch format {
+	case "pkcs12":
+		return pkcs12.Modern.WithRand(rand).Encode(key, certs[0], certs[1:], password)
+	case "jks":
+		return createJks(certs, key, alias, password, rand)
+	default:
+		return nil, fmt.Errorf("unknown keystore format '%s'", format)
+	}
+}
+
+// create Java keystore containing a single private key entry
+func createJks(certs []*x509.Certificate, key any, alias string, password string, rand io.Reader) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]keystore.Certificate, 0)
+	for i := 0; i < len(certs); i++ {
+		chain = append(chain, keystore.Certificate{Type: "X509", Content: certs[i].Raw})
+	}
+
+	ks := keystore.New(keystore.WithCustomRandomNumberGenerator(rand))
+	entry := keystore.PrivateKeyEntry{CreationTime: certs[0].NotBefore, PrivateKey: der, CertificateChain: chain}
+	if err := ks.SetPrivateKeyEntry(strings.ToLower(alias), entry, []byte(password)); err != nil {
+		return nil, err
+	}
+
+	result := strings.Builder{}
+	if err := ks.Store(&result, []byte(password)); err != nil {
+		return nil, err
+	}
+
+	return []byte(result.String()), nil
+}
+
+// pseudo random stream (SHA-256 in counter mode) seeded with the content of the keystore
+type deterministicReader struct {
+	seed    [sha256.Size]byte
+	counter uint64
+	buffer  []byte
+}
+
+func newDeterministicReader(format string, alias string, password string, key string, certs []*x509.Certificate) *deterministicReader {
+	hash := sha256.New()
+	for _, part := range []string{format, alias, password, key} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	for i := 0; i < len(certs); i++ {
+		hash.Write(certs[i].Raw)
+	}
+
+	reader := &deterministicReader{}
+	copy(reader.seed[:], hash.Sum(nil))
+	return reader
+}
+
+func (reader *deterministicReader) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		if len(reader.buffer) == 0 {
+			block := make([]byte, len(reader.seed)+8)
+			copy(block, reader.seed[:])
+			binary.BigEndian.PutUint64(block[len(reader.seed):], reader.counter)
+			sum := sha256.Sum256(block)
+			reader.buffer = sum[:]
+			reader.counter++
+		}
+
+		copied := copy(p[n:], reader.buffer)
+		reader.buffer = reader.buffer[copied:]
+		n += copied
+	}
+
+	return len(p), nil
+}
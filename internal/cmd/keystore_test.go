@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// TLS secret with PKCS#12 and JKS keystores
+func TestKeystoreSecret(t *testing.T) {
+	key, keyText := testCreateKey(t)
+	crt := testCreateCertificate("example.com", time.Now().AddDate(1, 0, 0), key, t)
+	values := testTlsEntry(crt, keyText, "secret-format=pkcs12,jks\nsecret-keystore-password=KeystorePassword")
+	values.SetValue("KeystorePassword", "changeit")
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources := make([]Resource, 0)
+	createTlsSecret("/example.com", "", "", NewNotes(values), values, &resources, &stdout, &stderr)
+
+	if len(resources) != 1 || stderr.Len() != 0 {
+		t.Errorf("unexpected result %d %s", len(resources), stderr.String())
+		return
+	}
+
+	p12, _ := resources[0].GetData("keystore.p12")
+	p12Key, p12Cert, _, err := pkcs12.DecodeChain(p12, "changeit")
+	if err != nil {
+		t.Errorf("cannot decode PKCS#12 %s", err)
+		return
+	}
+
+	if p12Cert.Subject.CommonName != "example.com" || !keyMatches(p12Cert, key) || p12Key == nil {
+		t.Errorf("unexpected PKCS#12 content")
+	}
+
+	jks, _ := resources[0].GetData("keystore.jks")
+	ks := keystore.New()
+	if err := ks.Load(bytes.NewReader(jks), []byte("changeit")); err != nil {
+		t.Errorf("cannot load JKS %s", err)
+		return
+	}
+
+	if _, err := ks.GetPrivateKeyEntry("example.com", []byte("changeit")); err != nil {
+		t.Errorf("missing private key entry %s", err)
+	}
+}
+
+// keystores are reproducible, the name hash does not change between renderings
+func TestKeystoreDeterministic(t *testing.T) {
+	key, keyText := testCreateKey(t)
+	crt := testCreateCertificate("example.com", time.Now().AddDate(1, 0, 0), key, t)
+	values := testTlsEntry(crt, keyText, "secret-format=pkcs12,jks\nsecret-keystore-password=KeystorePassword\nsecret-name-hash=true")
+	values.SetValue("KeystorePassword", "changeit")
+	entryMap := testNewEntryMap(nil)
+	entryMap.entries["/example.com"] = values
+	entryMap.paths = append(entryMap.paths, "/example.com")
+
+	names := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		stdout := strings.Builder{}
+		stderr := strings.Builder{}
+		resources, _ := collectResources(entryMap, nil, "", false, &stdout, &stderr)
+		if len(resources) != 1 || stderr.Len() != 0 {
+			t.Errorf("unexpected result %d %s", len(resources), stderr.String())
+			return
+		}
+		names = append(names, resources[0].name)
+	}
+
+	if names[0] != names[1] {
+		t.Errorf("name hash must be stable %s %s", names[0], names[1])
+	}
+}
+
+// keystore without password field
+func TestKeystoreMissingPassword(t *testing.T) {
+	key, keyText := testCreateKey(t)
+	crt := testCreateCertificate("example.com", time.Now().AddDate(1, 0, 0), key, t)
+	values := testTlsEntry(crt, keyText, "secret-format=pkcs12")
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources := make([]Resource, 0)
+	createTlsSecret("/example.com", "", "", NewNotes(values), values, &resources, &stdout, &stderr)
+
+	expected := "cannot create pkcs12 keystore of entry '/example.com': missing secret-keystore-password\n"
+	if stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stderr.String())
+	}
+}
+
+// get --as pkcs12
+func TestKeystoreGet(t *testing.T) {
+	db := "test/keystore.kdbx"
+	pw := "a1b2c3d4"
+	out := "test/keystore.p12"
+
+	key, keyText := testCreateKey(t)
+	crt := testCreateCertificate("example.com", time.Now().AddDate(1, 0, 0), key, t)
+
+	if !testCreateDatabase(db, pw, t) {
+		return
+	}
+
+	args := []string{"set", "-d", db, "-p", pw, "-e", "/certs/example.com", "-f", "UserName=" + crt, "-f", "Password=" + keyText,
+		"-f", "KeystorePassword=changeit", "-f", "Notes=secret-type=tls\\nsecret-keystore-password=KeystorePassword"}
+	if result := Run(args, &strings.Builder{}, &strings.Builder{}); result != 0 {
+		t.Errorf("set failed, result=%d", result)
+		return
+	}
+
+	args = []string{"get", "-d", db, "-p", pw, "-e", "/certs/example.com", "--as", "pkcs12", "-o", out}
+	stderr := strings.Builder{}
+	if result := Run(args, &strings.Builder{}, &stderr); result != 0 {
+		t.Errorf("get failed, result=%d %s", result, stderr.String())
+		return
+	}
+
+	p12, _ := os.ReadFile(out)
+	if _, _, _, err := pkcs12.DecodeChain(p12, "changeit"); err != nil {
+		t.Errorf("cannot decode PKCS#12 %s", err)
+	}
+
+	// invalid format
+	args = []string{"get", "-d", db, "-p", pw, "-e", "/certs/example.com", "--as", "pem"}
+	stderr = strings.Builder{}
+	if result := Run(args, &strings.Builder{}, &stderr); result == 0 || stderr.String() != "unknown keystore format pem\n" {
+		t.Errorf("get must fail, result=%d %s", result, stderr.String())
+	}
+
+	testDeleteFile(out, t)
+	testDeleteFile(db, t)
+}
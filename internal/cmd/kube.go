@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const fieldManager = "keepass-secret"                 // field manager of server-side apply
+const managedByLabel = "app.kubernetes.io/managed-by" // label of all applied resources (used by prune)
+const scopeLabel = "keepass-secret/scope"             // tag filter of the apply run (prune only deletes resources of the same scope)
+
+// label selector of the resources applied with the tag filter
+func managedSelector(filter *TagFilter) string {
+	return managedByLabel + "=" + fieldManager + "," + scopeLabel + "=" + filter.Scope()
+}
+
+// subset of the kubeconfig file used to connect to the API server
+type kubeConfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string      `yaml:"name"`
+		Cluster kubeCluster `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string      `yaml:"name"`
+		Context kubeContext `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string   `yaml:"name"`
+		User kubeUser `yaml:"user"`
+	} `yaml:"users"`
+}
+
+type kubeCluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	InsecureSkipTlsVerify    bool   `yaml:"insecure-skip-tls-verify"`
+}
+
+type kubeContext struct {
+	Cluster   string `yaml:"cluster"`
+	User      string `yaml:"user"`
+	Namespace string `yaml:"namespace"`
+}
+
+type kubeUser struct {
+	Token                 string `yaml:"token"`
+	TokenFile             string `yaml:"tokenFile"`
+	ClientCertificate     string `yaml:"client-certificate"`
+	ClientCertificateData string `yaml:"client-certificate-data"`
+	ClientKey             string `yaml:"client-key"`
+	ClientKeyData         string `yaml:"client-key-data"`
+	Username              string `yaml:"username"`
+	Password              string `yaml:"password"`
+}
+
+// minimal client of the Kubernetes API (Secrets, ConfigMaps and Namespaces)
+type KubeClient struct {
+	server    string
+	namespace string // default namespace of the context
+	token     string
+	username  string
+	password  string
+	client    *http.Client
+}
+
+// returns the kubeconfig file: option, first file of $KUBECONFIG or ~/.kube/config
+func kubeConfigPath(kubeconfig string) string {
+	if kubeconfig != "" {
+		return kubeconfig
+	}
+
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)[0]
+	}
+
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".kube", "config")
+}
+
+// create client of the context (current context if empty) defined in the kubeconfig file
+func NewKubeClient(kubeconfig string, context string) (*KubeClient, error) {
+	file := kubeConfigPath(kubeconfig)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read kubeconfig %s", err)
+	}
+
+	config := kubeConfig{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse kubeconfig %s: %s", file, err)
+	}
+
+	if context == "" {
+		context = config.CurrentContext
+	}
+
+	var kubeContext *kubeContext
+	for i := 0; i < len(config.Contexts); i++ {
+		if config.Contexts[i].Name == context {
+			kubeContext = &config.Contexts[i].Context
+		}
+	}
+	if kubeContext == nil {
+		return nil, fmt.Errorf("context '%s' not found in kubeconfig %s", context, file)
+	}
+
+	var cluster *kubeCluster
+	for i := 0; i < len(config.Clusters); i++ {
+		if config.Clusters[i].Name == kubeContext.Cluster {
+			cluster = &config.Clusters[i].Cluster
+		}
+	}
+	if cluster == nil || cluster.Server == "" {
+		return nil, fmt.Errorf("cluster '%s' not found in kubeconfig %s", kubeContext.Cluster, file)
+	}
+
+	user := &kubeUser{}
+	for i := 0; i < len(config.Users); i++ {
+		if config.Users[i].Name == kubeContext.User {
+			user = &config.Users[i].User
+		}
+	}
+
+	dir := filepath.Dir(file)
+	tlsConfig := &tls.Config{InsecureSkipVerify: cluster.InsecureSkipTlsVerify} //nolint:gosec // explicitly configured in kubeconfig
+	ca, err := readKubeData(cluster.CertificateAuthorityData, cluster.CertificateAuthority, dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read certificate authority: %s", err)
+	}
+	if ca != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("invalid certificate authority in kubeconfig")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	cert, err := readKubeData(user.ClientCertificateData, user.ClientCertificate, dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read client certificate: %s", err)
+	}
+	if cert != nil {
+		key, err := readKubeData(user.ClientKeyData, user.ClientKey, dir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read client key: %s", err)
+		}
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	token := user.Token
+	if token == "" && user.TokenFile != "" {
+		content, err := os.ReadFile(resolveKubePath(user.TokenFile, dir))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read token file %s", err)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+
+	namespace := kubeContext.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	return &KubeClient{
+		server:    strings.TrimSuffix(cluster.Server, "/"),
+		namespace: namespace,
+		token:     token,
+		username:  user.Username,
+		password:  user.Password,
+		client:    &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+	}, nil
+}
+
+// returns base64 decoded inline data or the content of the file, nil if neither is defined
+func readKubeData(data string, file string, dir string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+
+	if file != "" {
+		return os.ReadFile(resolveKubePath(file, dir))
+	}
+
+	return nil, nil
+}
+
+// paths in kubeconfig are relative to the kubeconfig file
+func resolveKubePath(file string, dir string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+
+	return filepath.Join(dir, file)
+}
+
+func (client *KubeClient) GetNamespace() string {
+	return client.namespace
+}
+
+// send request to API server, returns the response body
+// responses other than 2xx are returned as error containing the message of the API status
+func (client *KubeClient) do(method string, path string, query url.Values, contentType string, body []byte) ([]byte, int, error) {
+	target := client.server + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if client.token != "" {
+		request.Header.Set("Authorization", "Bearer "+client.token)
+	} else if client.username != "" {
+		request.SetBasicAuth(client.username, client.password)
+	}
+
+	response, err := client.client.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, response.StatusCode, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		status := struct {
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal(content, &status) != nil || status.Message == "" {
+			status.Message = response.Status
+		}
+		return content, response.StatusCode, errors.New(status.Message)
+	}
+
+	return content, response.StatusCode, nil
+}
+
+// returns the API path of a Secret/ConfigMap (collection path if name is empty)
+func kubeResourcePath(kind string, namespace string, name string) string {
+	plural := "secrets"
+	if kind == "ConfigMap" {
+		plural = "configmaps"
+	}
+
+	path := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/" + plural
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+
+	return path
+}
+
+// server-side apply of object, dryRun sends the request with dryRun=All
+func (client *KubeClient) Apply(path string, object any, dryRun bool) error {
+	query := url.Values{"fieldManager": {fieldManager}, "force": {"true"}}
+	if dryRun {
+		query.Set("dryRun", "All")
+	}
+
+	_, _, err := client.do(http.MethodPatch, path, query, "application/apply-patch+yaml", marshalJson(object))
+	return err
+}
+
+// delete object, dryRun sends the request with dryRun=All
+func (client *KubeClient) Delete(path string, dryRun bool) error {
+	query := url.Values{}
+	if dryRun {
+		query.Set("dryRun", "All")
+	}
+
+	_, _, err := client.do(http.MethodDelete, path, query, "", nil)
+	return err
+}
+
+// metadata of an applied or listed object
+type kubeObjectMeta struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Secret or ConfigMap as sent to or returned by the API server
+type kubeObject struct {
+	ApiVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   kubeObjectMeta    `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	Immutable  bool              `json:"immutable,omitempty"`
+	Data       map[string]string `json:"data,omitempty"` // base64 for Secrets, plain text for ConfigMaps
+}
+
+// list Secrets or ConfigMaps of all namespaces matching the label selector
+func (client *KubeClient) List(kind string, labelSelector string) ([]kubeObject, error) {
+	plural := "secrets"
+	if kind == "ConfigMap" {
+		plural = "configmaps"
+	}
+
+	query := url.Values{}
+	if labelSelector != "" {
+		query.Set("labelSelector", labelSelector)
+	}
+
+	content, _, err := client.do(http.MethodGet, "/api/v1/"+plural, query, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	list := struct {
+		Items []kubeObject `json:"items"`
+	}{}
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// returns Secret or ConfigMap, nil if it does not exist
+func (client *KubeClient) Get(kind string, namespace string, name string) (*kubeObject, error) {
+	content, status, err := client.do(http.MethodGet, kubeResourcePath(kind, namespace, name), nil, "", nil)
+	if status == http.StatusNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	object := &kubeObject{}
+	if err := json.Unmarshal(content, object); err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
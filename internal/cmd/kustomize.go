@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// write Kustomize secretGenerator/configMapGenerator for all resources
+// the kustomization.yaml is written to the specified directory
+// single-line values are written to env files, other values to separate files
+func writeKustomize(dir string, resources []Resource, stderr io.Writer) int {
+	if err := checkFileNames(resources); err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1 // failure, nothing written
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1 // failure
+	}
+
+	secretLines := make([]string, 0)
+	configMapLines := make([]string, 0)
+	for i := 0; i < len(resources); i++ {
+		resource := &resources[i]
+
+		var err error
+		if resource.IsSecret() {
+			err = resource.appendGenerator(dir, "secrets", &secretLines)
+		} else {
+			err = resource.appendGenerator(dir, "configmaps", &configMapLines)
+		}
+
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err)
+			return 1 // failure
+		}
+	}
+
+	lines := make([]string, 0)
+	lines = append(lines, "apiVersion: kustomize.config.k8s.io/v1beta1")
+	lines = append(lines, "kind: Kustomization")
+	lines = append(lines, "generatorOptions:")
+	lines = append(lines, "  disableNameSuffixHash: true")
+	if len(secretLines) > 0 {
+		lines = append(lines, "secretGenerator:")
+		lines = append(lines, secretLines...)
+	}
+	if len(configMapLines) > 0 {
+		lines = append(lines, "configMapGenerator:")
+		lines = append(lines, configMapLines...)
+	}
+
+	return writeFile(filepath.Join(dir, "kustomization.yaml"), &lines, stderr)
+}
+
+// names, namespaces and keys are used as file names
+// they must be valid Kubernetes names and keys (no path separators, no "..")
+func checkFileNames(resources []Resource) error {
+	for i := 0; i < len(resources); i++ {
+		resource := &resources[i]
+		if !namePattern.MatchString(resource.name) {
+			return fmt.Errorf("invalid name '%s' of %s", resource.name, resource.ref())
+		}
+
+		if resource.namespace != "" && !namespacePattern.MatchString(resource.namespace) {
+			return fmt.Errorf("invalid namespace '%s' of %s", resource.namespace, resource.ref())
+		}
+
+		for j := 0; j < len(resource.keys); j++ {
+			key := resource.keys[j]
+			if !dataKeyPattern.MatchString(key) || key == "." || key == ".." {
+				return fmt.Errorf("invalid key '%s' of %s", key, resource.ref())
+			}
+		}
+	}
+
+	return nil
+}
+
+// write env file and value files of resource to subdirectory of dir
+// and append the generator entry referencing these files
+func (resource *Resource) appendGenerator(dir string, subDir string, lines *[]string) error {
+	base := resource.name
+	if resource.namespace != "" {
+		base = resource.namespace + "-" + resource.name
+	}
+
+	envLines := make([]string, 0)
+	files := make([]string, 0)
+	for i := 0; i < len(resource.keys); i++ {
+		key := resource.keys[i]
+		value := resource.data[key]
+		if isEnvValue(value) {
+			envLines = append(envLines, key+"="+string(value))
+			continue
+		}
+
+		file := filepath.ToSlash(filepath.Join(subDir, base, key))
+		if err := os.MkdirAll(filepath.Join(dir, subDir, base), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), value, 0600); err != nil {
+			return err
+		}
+		files = append(files, key+"="+file)
+	}
+
+	*lines = append(*lines, "- name: "+quote(resource.name))
+	if resource.namespace != "" {
+		*lines = append(*lines, "  namespace: "+quote(resource.namespace))
+	}
+	if resource.IsSecret() {
+		*lines = append(*lines, "  type: "+quote(resource.secretType))
+	}
+	if resource.immutable {
+		*lines = append(*lines, "  options:")
+		*lines = append(*lines, "    immutable: true")
+	}
+
+	if len(envLines) > 0 {
+		envFile := filepath.ToSlash(filepath.Join(subDir, base+".env"))
+		if err := os.MkdirAll(filepath.Join(dir, subDir), 0700); err != nil {
+			return err
+		}
+		content := strings.Join(envLines, "\n") + "\n"
+		if err := os.WriteFile(filepath.Join(dir, envFile), []byte(content), 0600); err != nil {
+			return err
+		}
+		*lines = append(*lines, "  envs:")
+		*lines = append(*lines, "  - "+quote(envFile))
+	}
+
+	if len(files) > 0 {
+		*lines = append(*lines, "  files:")
+		for i := 0; i < len(files); i++ {
+			*lines = append(*lines, "  - "+quote(files[i]))
+		}
+	}
+
+	return nil
+}
+
+// check if value can be stored unmodified in an env file
+// (single line text without surrounding whitespace or quotes)
+func isEnvValue(value []byte) bool {
+	str := string(value)
+	if !utf8.ValidString(str) || strings.ContainsAny(str, "\r\n") {
+		return false
+	}
+
+	if strings.TrimSpace(str) != str {
+		return false
+	}
+
+	return !strings.HasPrefix(str, "\"") && !strings.HasPrefix(str, "'")
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// data available in the name template (option --name-template)
+// e.g. "{{.Group}}-{{.Title}}" or "{{replace .Group "/" "-" | lower}}-{{.Title}}"
+type nameData struct {
+	Path   string   // full path of the entry e.g. /group-1/sub-group/entry-1
+	Group  string   // group path without leading "/" e.g. group-1/sub-group
+	Groups []string // group names e.g. [group-1 sub-group]
+	Title  string   // entry title
+	Tags   []string // tags of the entry
+}
+
+var nameFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"replace": func(str string, old string, new string) string { return strings.ReplaceAll(str, old, new) },
+	"join":    func(sep string, list []string) string { return strings.Join(list, sep) },
+}
+
+// parse name template, returns nil if text is empty
+func parseNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	return template.New("name").Funcs(nameFuncs).Option("missingkey=error").Parse(text)
+}
+
+// determine name of Secret/ConfigMap
+// "secret-name" in Notes takes precedence over the name template
+// an empty name is returned if neither is defined (title will be used as name)
+func resolveName(path string, notes *Notes, values Entry, tags []string, tmpl *template.Template) (string, error) {
+	if name := notes.Get("name"); name != "" {
+		return name, nil
+	}
+
+	title, _ := values.GetValue("Title")
+	if tmpl == nil || title == "" {
+		return "", nil
+	}
+
+	groups := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	groups = groups[:len(groups)-1]
+	data := nameData{Path: path, Group: strings.Join(groups, "/"), Groups: groups, Title: title, Tags: tags}
+
+	name := strings.Builder{}
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", err
+	}
+
+	return name.String(), nil
+}
+
+// check that name is a valid Kubernetes resource name (DNS-1123 subdomain)
+func isValidName(name string) bool {
+	return len(name) <= 253 && namePattern.MatchString(name)
+}
+
+// check that no two entries create resources of the same kind with identical namespace/name
+func checkDuplicates(resources []Resource, stderr io.Writer) bool {
+	ok := true
+	paths := make(map[string]string)
+	for i := 0; i < len(resources); i++ {
+		resource := &resources[i]
+		key := resource.kind + " " + resource.namespace + "/" + resource.name
+		if path, exists := paths[key]; exists && path != resource.path {
+			fmt.Fprintf(stderr, "%s '%s/%s' is created by entry '%s' and entry '%s'\n", resource.kind, resource.namespace, resource.name, path, resource.path)
+			ok = false
+			continue
+		}
+
+		paths[key] = resource.path
+	}
+
+	return ok
+}
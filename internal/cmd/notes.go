@@ -1,8 +1,34 @@
 package cmd
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
 
 const prefix = "secret-"
+const fieldPrefix = "k8s." // alternative prefix of custom string fields e.g. "k8s.type"
+const configPrefix = "config-"
+
+// reserved keys which are not mapped to secret values
+var reservedKeys = map[string]bool{
+	"type":              true,
+	"tags":              true,
+	"namespace":         true,
+	"name":              true,
+	"docker-include":    true,
+	"docker-email":      true,
+	"docker-format":     true,
+	"tls-ca":            true,
+	"tls-passphrase":    true,
+	"format":            true,
+	"keystore-password": true,
+	"string-data":       true,
+	"immutable":         true,
+	"name-hash":         true,
+}
 
 // models the contents of the 'Notes' field as a key/value map
 // each line in the 'Notes' field is treated as an key/value pair
@@ -10,9 +36,21 @@ const prefix = "secret-"
 // the stored key exclude the prefix
 // e.g. the line "secret-postgresql-user=UserName" will be stored
 // with key="postgresql-user" and value="UserName"
+// lines starting with the "config-" prefix are stored separately
+// and define the (non-sensitive) data of a ConfigMap
+// the same annotations can be defined as custom string fields named
+// e.g. "k8s.password" or "k8s.config-url", reserved keys also as "secret-type"
+// (other "secret-" fields are ordinary fields e.g. an API key)
+// a field takes precedence over a line of the 'Notes' field with the same key
+// keys prefixed by a namespace e.g. "secret-dev:password=PasswordDev" override
+// the key in this namespace (see ForNamespace), a leading colon escapes reserved keys
 type Notes struct {
-	keys    []string
-	entries map[string]string
+	keys       []string
+	entries    map[string]string
+	configKeys []string
+	config     map[string]string
+	duplicates []string // annotations defined more than once in the 'Notes' field e.g. "secret-user"
+	errors     []string // syntax errors of the 'Notes' field
 }
 
 func (notes *Notes) Get(key string) string {
@@ -24,7 +62,7 @@ func (notes *Notes) GetKeys() []string {
 
 	for i := 0; i < len(notes.keys); i++ {
 		key := notes.keys[i]
-		if key != "type" && key != "tags" && key != "namespace" {
+		if namespace, _ := splitScopedKey(key); !reservedKeys[key] && namespace == "" {
 			result = append(result, key)
 		}
 	}
@@ -32,24 +70,265 @@ func (notes *Notes) GetKeys() []string {
 	return result
 }
 
+// returns all annotations defined more than once in the 'Notes' field
+func (notes *Notes) GetDuplicates() []string {
+	return notes.duplicates
+}
+
+// returns the syntax errors of the 'Notes' field, invalid lines are ignored
+func (notes *Notes) GetErrors() []string {
+	return notes.errors
+}
+
+// returns the keys of all "config-" lines
+func (notes *Notes) GetConfigKeys() []string {
+	result := make([]string, 0)
+
+	for i := 0; i < len(notes.configKeys); i++ {
+		if namespace, _ := splitScopedKey(notes.configKeys[i]); namespace == "" {
+			result = append(result, notes.configKeys[i])
+		}
+	}
+
+	return result
+}
+
+// returns a copy in which the keys scoped to the namespace e.g. "dev:password"
+// replace (or add) the unscoped keys e.g. "password"
+func (notes *Notes) ForNamespace(namespace string) *Notes {
+	if namespace == "" {
+		return notes
+	}
+
+	scoped := &Notes{
+		keys:       append(make([]string, 0, len(notes.keys)), notes.keys...),
+		entries:    make(map[string]string),
+		configKeys: append(make([]string, 0, len(notes.configKeys)), notes.configKeys...),
+		config:     make(map[string]string),
+		duplicates: notes.duplicates,
+		errors:     notes.errors,
+	}
+	for key, value := range notes.entries {
+		scoped.entries[key] = value
+	}
+	for key, value := range notes.config {
+		scoped.config[key] = value
+	}
+
+	for i := 0; i < len(notes.keys); i++ {
+		if keyNamespace, key := splitScopedKey(notes.keys[i]); keyNamespace == namespace {
+			scoped.set(key, notes.entries[notes.keys[i]])
+		}
+	}
+
+	for i := 0; i < len(notes.configKeys); i++ {
+		if keyNamespace, key := splitScopedKey(notes.configKeys[i]); keyNamespace == namespace {
+			scoped.setConfig(key, notes.config[notes.configKeys[i]])
+		}
+	}
+
+	return scoped
+}
+
+// reserved key, optionally prefixed by a namespace e.g. "dev:namespace"
+func isReservedKey(key string) bool {
+	_, name := splitScopedKey(key)
+	return reservedKeys[name]
+}
+
+// split key "dev:password" into namespace and key, the namespace is empty for unscoped and escaped (":type") keys
+func splitScopedKey(key string) (string, string) {
+	pos := strings.Index(key, ":")
+	if pos < 1 {
+		return "", key
+	}
+
+	return key[:pos], key[pos+1:]
+}
+
+func (notes *Notes) GetConfig(key string) string {
+	return notes.config[key]
+}
+
+// add or replace secret annotation
+func (notes *Notes) set(key string, value string) {
+	if _, ok := notes.entries[key]; !ok {
+		notes.keys = append(notes.keys, key)
+	}
+	notes.entries[key] = value
+}
+
+// add or replace config annotation
+func (notes *Notes) setConfig(key string, value string) {
+	if _, ok := notes.config[key]; !ok {
+		notes.configKeys = append(notes.configKeys, key)
+	}
+	notes.config[key] = value
+}
+
 func NewNotes(values Entry) *Notes {
-	notes := Notes{make([]string, 0), make(map[string]string)}
+	notes := Notes{make([]string, 0), make(map[string]string), make([]string, 0), make(map[string]string), make([]string, 0), make([]string, 0)}
 
 	if notesStr, ok := values.GetValue("Notes"); ok {
-		lines := strings.Split(strings.ReplaceAll(notesStr, "\r", ""), "\n")
-		for i := 0; i < len(lines); i++ {
-			line := lines[i]
-			if strings.HasPrefix(line, prefix) {
-				pos := strings.Index(line, "=")
-				if pos > len(prefix) {
-					key := line[len(prefix):pos]
-					value := line[pos+1:]
-					notes.entries[key] = value
-					notes.keys = append(notes.keys, key)
-				}
-			}
+		notes.parse(notesStr)
+	}
+
+	// custom string fields override lines of the notes (sorted for a stable key order)
+	names := values.GetNames()
+	sort.Strings(names)
+	for i := 0; i < len(names); i++ {
+		name := names[i]
+		value, _ := values.GetValue(name)
+		if strings.HasPrefix(name, prefix) && isReservedKey(name[len(prefix):]) {
+			notes.set(name[len(prefix):], value)
+		} else if strings.HasPrefix(name, fieldPrefix+configPrefix) && len(name) > len(fieldPrefix+configPrefix) {
+			notes.setConfig(name[len(fieldPrefix+configPrefix):], value)
+		} else if strings.HasPrefix(name, fieldPrefix) && len(name) > len(fieldPrefix) {
+			notes.set(name[len(fieldPrefix):], value)
 		}
 	}
 
 	return &notes
 }
+
+// parse the annotations of the 'Notes' field
+//
+//	# comment                        lines starting with # are ignored
+//	secret-key=value                 plain value up to the end of the line
+//	secret-key="a \"quoted\"\nvalue"  double quoted value with escapes (\n, \t, \", \\, \uXXXX)
+//	secret-key='raw value'           single quoted value without escapes
+//	secret-"key=with=equals"=value   quoted key (same quoting rules as values)
+//	secret-key<<EOF                  multi-line value up to the line "EOF" (line breaks are kept, no trailing line break)
+//
+// quoted values may be followed by a comment; lines without prefix are ignored
+func (notes *Notes) parse(text string) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r", ""), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		annotationPrefix := ""
+		if strings.HasPrefix(line, prefix) {
+			annotationPrefix = prefix
+		} else if strings.HasPrefix(line, configPrefix) {
+			annotationPrefix = configPrefix
+		} else {
+			continue // comment or free text
+		}
+
+		key, rest, err := parseAnnotationKey(line[len(annotationPrefix):])
+		if err != nil {
+			notes.errors = append(notes.errors, fmt.Sprintf("line %d: %s", i+1, err))
+			continue
+		}
+
+		if key == "" {
+			continue // no annotation e.g. "secret-" without "="
+		}
+
+		var value string
+		if strings.HasPrefix(rest, "<<") {
+			delimiter := strings.TrimSpace(rest[2:])
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != delimiter {
+				end++
+			}
+
+			if delimiter == "" || end >= len(lines) {
+				notes.errors = append(notes.errors, fmt.Sprintf("line %d: missing end of multi-line value '%s'", i+1, delimiter))
+				continue
+			}
+
+			value = strings.Join(lines[i+1:end], "\n")
+			i = end
+		} else {
+			value, err = parseAnnotationValue(rest[1:])
+			if err != nil {
+				notes.errors = append(notes.errors, fmt.Sprintf("line %d: %s", i+1, err))
+				continue
+			}
+		}
+
+		if annotationPrefix == prefix {
+			if _, ok := notes.entries[key]; ok {
+				notes.duplicates = append(notes.duplicates, prefix+key)
+			}
+			notes.set(key, value)
+		} else {
+			if _, ok := notes.config[key]; ok {
+				notes.duplicates = append(notes.duplicates, configPrefix+key)
+			}
+			notes.setConfig(key, value)
+		}
+	}
+}
+
+// parse (optionally quoted) key of an annotation
+// returns the key and the rest of the line starting with "=" or "<<"
+// an empty key is returned if the text is no annotation
+func parseAnnotationKey(text string) (string, string, error) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		key, rest, err := unquoteAnnotation(text)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid quoted key: %s", err)
+		}
+
+		if !strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "<<") {
+			return "", "", errors.New("missing '=' after quoted key")
+		}
+
+		return key, rest, nil
+	}
+
+	pos := strings.Index(text, "=")
+	if heredoc := strings.Index(text, "<<"); heredoc != -1 && (pos == -1 || heredoc < pos) {
+		pos = heredoc
+	}
+
+	if pos < 1 {
+		return "", "", nil
+	}
+
+	return text[:pos], text[pos:], nil
+}
+
+// parse (optionally quoted) value of an annotation
+// unquoted values are taken as they are, quoted values may be followed by a comment
+func parseAnnotationValue(text string) (string, error) {
+	if !strings.HasPrefix(text, "\"") && !strings.HasPrefix(text, "'") {
+		return text, nil
+	}
+
+	value, rest, err := unquoteAnnotation(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted value: %s", err)
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected text after quoted value: %s", rest)
+	}
+
+	return value, nil
+}
+
+// unquote leading double quoted (with escapes) or single quoted (raw) string
+// returns the unquoted string and the remaining text
+func unquoteAnnotation(text string) (string, string, error) {
+	if strings.HasPrefix(text, "'") {
+		end := strings.Index(text[1:], "'")
+		if end == -1 {
+			return "", "", errors.New("missing closing quote")
+		}
+		return text[1 : end+1], text[end+2:], nil
+	}
+
+	quoted, err := strconv.QuotedPrefix(text)
+	if err != nil {
+		return "", "", errors.New("missing closing quote or invalid escape sequence")
+	}
+
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", "", err
+	}
+
+	return value, text[len(quoted):], nil
+}
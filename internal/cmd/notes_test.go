@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// create notes of entry with the given 'Notes' field
+func testNewNotes(text string) *Notes {
+	values := NewEntry()
+	values.SetValue("Notes", text)
+	return NewNotes(*values)
+}
+
+// grammar of annotations
+func TestNotesParse(t *testing.T) {
+	tests := []struct {
+		text     string
+		key      string
+		expected string
+	}{
+		{"secret-user=UserName", "user", "UserName"},
+		{"secret-user=a=b", "user", "a=b"},
+		{"secret-user= UserName ", "user", " UserName "},
+		{"secret-user=\"a \\\"quoted\\\"\\nvalue\"", "user", "a \"quoted\"\nvalue"},
+		{"secret-user=\"tab\\tvalue\" # comment", "user", "tab\tvalue"},
+		{"secret-user='raw \\n value'", "user", "raw \\n value"},
+		{"secret-\"key=with=equals\"=Password", "key=with=equals", "Password"},
+		{"secret-'raw key'=Password", "raw key", "Password"},
+		{"secret-LOG_LEVEL=literal:debug", "LOG_LEVEL", "literal:debug"},
+		{"secret-config<<EOF\nline 1\n  line 2\nEOF\nsecret-user=UserName", "config", "line 1\n  line 2"},
+		{"secret-config<<END\nEOF\nEND", "config", "EOF"},
+		{"secret-\"config\"<<EOF\nliteral:a=b\nEOF", "config", "literal:a=b"},
+		{"# secret-user=Comment\nsecret-user=UserName", "user", "UserName"},
+		{"secret-user=UserName\r\nsecret-type=opaque\r\n", "user", "UserName"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		notes := testNewNotes(tests[i].text)
+		if len(notes.GetErrors()) != 0 {
+			t.Errorf("test %d: unexpected errors %v", i, notes.GetErrors())
+		}
+
+		if actual := notes.Get(tests[i].key); actual != tests[i].expected {
+			t.Errorf("test %d: expected: %q", i, tests[i].expected)
+			t.Errorf("test %d: actual:   %q", i, actual)
+		}
+	}
+}
+
+// syntax errors, invalid lines are ignored
+func TestNotesParseErrors(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"secret-user=\"unterminated", "line 1: invalid quoted value: missing closing quote or invalid escape sequence"},
+		{"secret-user=\"invalid \\q escape\"", "line 1: invalid quoted value: missing closing quote or invalid escape sequence"},
+		{"secret-user='unterminated", "line 1: invalid quoted value: missing closing quote"},
+		{"secret-user=\"value\" text", "line 1: unexpected text after quoted value: text"},
+		{"secret-\"key\"Password", "line 1: missing '=' after quoted key"},
+		{"secret-type=opaque\nsecret-config<<EOF\nline 1", "line 2: missing end of multi-line value 'EOF'"},
+		{"secret-config<<", "line 1: missing end of multi-line value ''"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		notes := testNewNotes(tests[i].text)
+		actual := strings.Join(notes.GetErrors(), "\n")
+		if actual != tests[i].expected {
+			t.Errorf("test %d: expected: %s", i, tests[i].expected)
+			t.Errorf("test %d: actual:   %s", i, actual)
+		}
+
+		if len(notes.GetKeys()) != 0 {
+			t.Errorf("test %d: invalid line must be ignored %v", i, notes.GetKeys())
+		}
+	}
+}
+
+// literal and multi-line values in secrets
+func TestNotesSecret(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/app", "Title": "app", "Password": "secret",
+			"Notes": "secret-type=opaque\nsecret-LOG_LEVEL=literal:debug\nsecret-\"config.ini\"<<EOF\ntmpl:[db]\npassword={{.Password}}\nEOF\nsecret-bad=\"x"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources, _ := collectResources(entryMap, nil, "", false, &stdout, &stderr)
+	if len(resources) != 1 {
+		t.Errorf("unexpected resources %d", len(resources))
+		return
+	}
+
+	level, _ := resources[0].GetData("LOG_LEVEL")
+	config, _ := resources[0].GetData("config.ini")
+	if string(level) != "debug" || string(config) != "[db]\npassword=secret" {
+		t.Errorf("unexpected data %q %q", level, config)
+	}
+
+	expected := "entry '/app' contains invalid annotation in line 7: invalid quoted value: missing closing quote or invalid escape sequence\n"
+	if stderr.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stderr.String())
+	}
+}
+
+// namespace-scoped mappings override the default mapping in their namespace
+func TestNotesNamespaceOverride(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/db", "Title": "db", "UserName": "admin", "Password": "secret", "PasswordDev": "dev-secret", "URL": "db.example.com",
+			"Notes": "secret-type=opaque\nsecret-namespace=dev,prod\nsecret-user=UserName\nsecret-password=Password\nsecret-dev:password=PasswordDev\nsecret-dev:debug=literal:true\nconfig-host=URL\nconfig-prod:host=literal:db.prod\nsecret-:type=UserName"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources, _ := collectResources(entryMap, nil, "", false, &stdout, &stderr)
+	if len(resources) != 4 || stderr.String() != "" {
+		t.Errorf("unexpected resources %d %s", len(resources), stderr.String())
+		return
+	}
+
+	expected := []string{"dev user,password,type,debug", "dev host", "prod user,password,type", "prod host"}
+	for i := 0; i < len(resources); i++ {
+		if actual := resources[i].namespace + " " + strings.Join(resources[i].GetKeys(), ","); actual != expected[i] {
+			t.Errorf("expected: %s", expected[i])
+			t.Errorf("actual:   %s", actual)
+		}
+	}
+
+	devPassword, _ := resources[0].GetData("password")
+	prodPassword, _ := resources[2].GetData("password")
+	devHost, _ := resources[1].GetData("host")
+	prodHost, _ := resources[3].GetData("host")
+	if string(devPassword) != "dev-secret" || string(prodPassword) != "secret" || string(devHost) != "db.example.com" || string(prodHost) != "db.prod" {
+		t.Errorf("unexpected data %s %s %s %s", devPassword, prodPassword, devHost, prodHost)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	flag "github.com/spf13/pflag"
@@ -13,7 +14,7 @@ import (
 var version = "0.0.0" // application version (must be set in build)
 var commit = "local"  // commit hash (must be set in build)
 
-// arrayFlags collects multiple string options into array (used for option 'field')
+// arrayFlags collects multiple string options into array (used for options 'field', 'tag', 'database', 'password' and 'key-file')
 type arrayFlags []string
 
 func (arr *arrayFlags) String() string {
@@ -31,17 +32,44 @@ func (arr *arrayFlags) Type() string {
 
 // stores all commandline options
 type Options struct {
-	flags  *flag.FlagSet
-	cmd    string
-	db     string
-	pw     string
-	path   string
-	tag    string
-	fields arrayFlags
-	out    string
-	in     string
-	dryRun bool
-	quiet  bool
+	flags        *flag.FlagSet
+	cmd          string
+	db           string
+	pw           string
+	dbs          arrayFlags // layered databases (later databases override earlier ones)
+	pws          arrayFlags // password of each database (a single password is used for all)
+	keyFile      string     // key file of the first database
+	keyFiles     arrayFlags // key file of each database (a single key file is used for all, empty for none)
+	pwCommand    string     // password command of the profile
+	path         string
+	tags         arrayFlags
+	tagSource    string
+	tagFilter    *TagFilter
+	fields       arrayFlags
+	out          string
+	in           string
+	dryRun       string // client or server
+	quiet        bool
+	listen       string
+	token        string
+	tlsCert      string
+	tlsKey       string
+	format       string
+	nameTemplate string
+	warnDays     int
+	all          bool
+	strict       bool
+	noResolve    bool
+	stringData   bool
+	as           string
+	kubeconfig   string
+	context      string
+	namespace    string
+	createNs     bool
+	prune        bool
+	force        bool
+	diffAgainst  string
+	layers       bool
 }
 
 func NewOptions() Options {
@@ -58,7 +86,7 @@ func (options *Options) parseCmd(args []string) ([]string, error) {
 
 	options.cmd = args[0]
 
-	if options.cmd != "secrets" && options.cmd != "get" && options.cmd != "export" && options.cmd != "import" && options.cmd != "init" && options.cmd != "set" {
+	if options.cmd != "secrets" && options.cmd != "get" && options.cmd != "export" && options.cmd != "import" && options.cmd != "init" && options.cmd != "set" && options.cmd != "serve" && options.cmd != "certs" && options.cmd != "list" && options.cmd != "lint" && options.cmd != "apply" && options.cmd != "drift" {
 		return make([]string, 0), errors.New("unknown command " + options.cmd)
 	}
 
@@ -67,15 +95,40 @@ func (options *Options) parseCmd(args []string) ([]string, error) {
 }
 
 func (options *Options) parseOptions(args []string, stderr io.Writer) bool {
-	dbFlag := options.flags.StringP("database", "d", "", "keepass 2.30 file")
-	pwFlag := options.flags.StringP("password", "p", "", "password")
 	pathFlag := options.flags.StringP("entry", "e", "", "path of keepass entry")
-	tagFlag := options.flags.StringP("tag", "t", "", "filter by tag")
+	tagSourceFlag := options.flags.StringP("tag-source", "", "both", "tags used by tag filter (notes, native, both)")
 	outFlag := options.flags.StringP("out", "o", "", "output filename")
 	inFlag := options.flags.StringP("in", "i", "", "input filename")
-	dryRunFlag := options.flags.BoolP("dry-run", "", false, "do not modify database")
+	dryRunFlag := options.flags.StringP("dry-run", "", "", "do not modify database (apply: client or server)")
+	options.flags.Lookup("dry-run").NoOptDefVal = "client"
 	quietFlag := options.flags.BoolP("quiet", "q", false, "suppress all normal output")
+	formatFlag := options.flags.StringP("format", "", "", "output format (secrets: manifest, kustomize, helm; certs: text, json; lint: text, json, sarif)")
+	warnDaysFlag := options.flags.IntP("warn-days", "", expiryWarnDays, "certs command fails if a certificate expires within this number of days")
+	asFlag := options.flags.StringP("as", "", "", "get command converts certificate and key to keystore (pkcs12, jks)")
+	strictFlag := options.flags.BoolP("strict", "", false, "secrets command fails on any warning and does not write the output")
+	stringDataFlag := options.flags.BoolP("string-data", "", false, "secrets command writes text values as stringData")
+	noResolveFlag := options.flags.BoolP("no-resolve", "", false, "do not resolve field references {REF:...} and placeholders {USERNAME}")
+	allFlag := options.flags.BoolP("all", "", false, "certs command scans all fields and attachments for PEM certificates")
+	nameTemplateFlag := options.flags.StringP("name-template", "", "", "template of secret names e.g. {{.Group}}-{{.Title}}")
+	listenFlag := options.flags.StringP("listen", "", ":8080", "listen address of serve command")
+	tokenFlag := options.flags.StringP("token", "", "", "bearer token required by serve command")
+	tlsCertFlag := options.flags.StringP("tls-cert", "", "", "PEM certificate file of serve command")
+	tlsKeyFlag := options.flags.StringP("tls-key", "", "", "PEM private key file of serve command")
+	kubeconfigFlag := options.flags.StringP("kubeconfig", "", "", "kubeconfig file of apply and drift command (default $KUBECONFIG or ~/.kube/config)")
+	contextFlag := options.flags.StringP("context", "", "", "kubeconfig context of apply and drift command (default current context)")
+	namespaceFlag := options.flags.StringP("namespace", "n", "", "apply and drift command use this namespace for secrets without namespace (default namespace of context)")
+	createNsFlag := options.flags.BoolP("create-namespace", "", false, "apply command creates missing namespaces")
+	diffAgainstFlag := options.flags.StringP("diff-against", "", "", "secrets command compares with this manifest instead of writing the output")
+	pruneFlag := options.flags.BoolP("prune", "", false, "apply command deletes managed secrets which no longer exist in the database")
+	forceFlag := options.flags.BoolP("force", "", false, "init command overwrites an existing database")
+	configFlag := options.flags.StringP("config", "", "", "config file (default .keepass-secret.yaml or $XDG_CONFIG_HOME/keepass-secret/config.yaml)")
+	profileFlag := options.flags.StringP("profile", "", "", "profile of the config file (default: default-profile of the config file)")
+	layersFlag := options.flags.BoolP("layers", "", false, "report the database of each value (multiple -d/--database parameters)")
+	options.flags.VarP(&options.dbs, "database", "d", "keepass 2.30 file (multiple files are merged, later files override fields of earlier ones)")
+	options.flags.VarP(&options.pws, "password", "p", "password (one per database or one for all)")
+	options.flags.VarP(&options.keyFiles, "key-file", "k", "key file used in addition to the password (one per database or one for all, empty for none)")
 	options.flags.VarP(&options.fields, "field", "f", "field name and value")
+	options.flags.VarP(&options.tags, "tag", "t", "filter by tag expression e.g. 'prod && !legacy' (multiple filters are combined with or)")
 
 	err := options.flags.Parse(args)
 	if err != nil {
@@ -83,19 +136,138 @@ func (options *Options) parseOptions(args []string, stderr io.Writer) bool {
 		return false
 	}
 
-	options.db = *dbFlag
-	options.pw = *pwFlag
 	options.path = *pathFlag
-	options.tag = *tagFlag
+	options.tagSource = *tagSourceFlag
 	options.out = *outFlag
 	options.in = *inFlag
 	options.dryRun = *dryRunFlag
+	if dryRun, err := strconv.ParseBool(options.dryRun); err == nil {
+		// boolean values of the former flag, e.g. --dry-run=true
+		options.dryRun = ""
+		if dryRun {
+			options.dryRun = "client"
+		}
+	}
 	options.quiet = *quietFlag
+	options.format = *formatFlag
+	options.warnDays = *warnDaysFlag
+	options.all = *allFlag
+	options.strict = *strictFlag
+	options.noResolve = *noResolveFlag
+	options.stringData = *stringDataFlag
+	options.as = *asFlag
+	options.nameTemplate = *nameTemplateFlag
+	options.listen = *listenFlag
+	options.token = *tokenFlag
+	options.tlsCert = *tlsCertFlag
+	options.tlsKey = *tlsKeyFlag
+	options.kubeconfig = *kubeconfigFlag
+	options.context = *contextFlag
+	options.namespace = *namespaceFlag
+	options.createNs = *createNsFlag
+	options.prune = *pruneFlag
+	options.force = *forceFlag
+	options.diffAgainst = *diffAgainstFlag
+	options.layers = *layersFlag
+
+	if !options.applyProfile(*configFlag, *profileFlag, stderr) {
+		return false
+	}
+
+	if len(options.pws) == 0 && os.Getenv("KSPASSWORD") != "" {
+		options.pws = append(options.pws, os.Getenv("KSPASSWORD"))
+	}
+
+	if len(options.pws) == 0 && options.pwCommand != "" {
+		pw, err := runPasswordCommand(options.pwCommand)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err)
+			return false
+		}
+		options.pws = append(options.pws, pw)
+	}
+
+	if len(options.dbs) > 0 {
+		options.db = options.dbs[0]
+	}
+
+	if len(options.pws) > 0 {
+		options.pw = options.pws[0]
+	}
+
+	if len(options.keyFiles) > 0 {
+		options.keyFile = options.keyFiles[0]
+	}
+
+	if options.token == "" && os.Getenv("KSTOKEN") != "" {
+		options.token = os.Getenv("KSTOKEN")
+	}
+
+	return true
+}
+
+// fill options which are not set on the commandline from the selected profile of the config file
+// precedence: commandline, environment (KSPASSWORD, KSTOKEN), profile, defaults
+func (options *Options) applyProfile(config string, name string, stderr io.Writer) bool {
+	file, workingDir := configPath(config)
+	if file == "" {
+		if name != "" {
+			fmt.Fprintf(stderr, "profile '%s' requires a config file %s\n", name, configFile)
+			return false
+		}
+		return true // no config file
+	}
 
-	if options.pw == "" && os.Getenv("KSPASSWORD") != "" {
-		options.pw = os.Getenv("KSPASSWORD")
+	profile, err := loadProfile(file, name)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return false
+	}
+
+	if profile == nil {
+		return true // no profile selected
+	}
+
+	// init requires an explicit database, it must not overwrite the database of the profile
+	// key files and password command belong to the databases of the profile
+	profileDbs := len(options.dbs) == 0 && options.cmd != "init"
+	if profileDbs {
+		options.dbs = append(options.dbs, profile.Databases...)
+	}
+
+	if profileDbs && len(options.keyFiles) == 0 {
+		options.keyFiles = append(options.keyFiles, profile.KeyFiles...)
+	}
+
+	if len(options.tags) == 0 {
+		options.tags = append(options.tags, profile.Tags...)
+	}
+
+	if !options.flags.Changed("tag-source") && profile.TagSource != "" {
+		options.tagSource = profile.TagSource
+	}
+
+	if !options.flags.Changed("name-template") {
+		options.nameTemplate = profile.NameTemplate
 	}
 
+	// output format and file of the secrets command
+	if options.cmd == "secrets" && options.format == "" {
+		options.format = profile.Format
+	}
+
+	if options.cmd == "secrets" && options.out == "" {
+		options.out = profile.Out
+	}
+
+	// a config file of e.g. a cloned repository must not run commands
+	if !profileDbs {
+		return true
+	} else if workingDir && profile.PasswordCommand != "" {
+		fmt.Fprintf(stderr, "password-command of %s ignored, use --config %s to run it\n", file, file)
+	} else {
+		options.pwCommand = profile.PasswordCommand
+	}
 	return true
 }
 
@@ -121,14 +293,26 @@ func (options *Options) getUsage() string {
 	usage := strings.Builder{}
 
 	usage.WriteString(fmt.Sprintf("keepass-secret %s (%s)\n", version, commit))
-	usage.WriteString("usage: keepass-secret secrets -d keepass.kdbx -p 1234 -o secrets.yaml [--tag abc] [--quiet]\n")
+	usage.WriteString("usage: keepass-secret secrets -d keepass.kdbx -p 1234 -o secrets.yaml [--tag expr] [--format manifest|kustomize|helm] [--name-template tmpl] [--strict] [--string-data] [--quiet]\n")
+	usage.WriteString("       keepass-secret secrets -d keepass.kdbx -p 1234 --diff-against secrets.yaml [--tag expr] [--name-template tmpl] [--quiet]\n")
 	usage.WriteString("       keepass-secret get     -d keepass.kdbx -p 1234 -e /entry-1 -f Password\n")
+	usage.WriteString("       keepass-secret get     -d keepass.kdbx -p 1234 -e /entry-1 --as pkcs12|jks [-o keystore.p12]\n")
 	usage.WriteString("       keepass-secret set     -d keepass.kdbx -p 1234 -e /entry-1 -f Password=1234 -f UserName=admin\n")
-	usage.WriteString("       keepass-secret export  -d keepass.kdbx -p 1234 -o export.json\n")
+	usage.WriteString("       keepass-secret export  -d keepass.kdbx -p 1234 -o export.json [--tag expr]\n")
 	usage.WriteString("       keepass-secret import  -d keepass.kdbx -p 1234 -i import.json [--dry-run]\n")
 	usage.WriteString("       keepass-secret init    -d keepass.kdbx -p 1234\n")
+	usage.WriteString("       keepass-secret list    -d keepass.kdbx -p 1234 [--tag expr] [--tag-source notes|native|both]\n")
+	usage.WriteString("       keepass-secret lint    -d keepass.kdbx -p 1234 [--tag expr] [--format text|json|sarif]\n")
+	usage.WriteString("       keepass-secret certs   -d keepass.kdbx -p 1234 [--tag expr] [--warn-days 30] [--all] [--format text|json]\n")
+	usage.WriteString("       keepass-secret apply   -d keepass.kdbx -p 1234 [--tag expr] [--kubeconfig file] [--context ctx] [-n namespace] [--create-namespace] [--prune] [--dry-run=client|server]\n")
+	usage.WriteString("       keepass-secret drift   -d keepass.kdbx -p 1234 [--tag expr] [--kubeconfig file] [--context ctx] [-n namespace]\n")
+	usage.WriteString("       keepass-secret serve   -d keepass.kdbx -p 1234 --token abc [--listen :8080] [--tls-cert crt.pem --tls-key key.pem]\n")
 	usage.WriteString("\n")
+	usage.WriteString("Defaults of all options can be defined in profiles of the config file .keepass-secret.yaml (select with --profile name)\n")
+	usage.WriteString("Multiple databases can be layered with -d base.kdbx -p 1234 -d prod.kdbx -p 5678 [--layers] (later databases override fields of earlier ones)\n")
+	usage.WriteString("Field references {REF:...} and placeholders {USERNAME} are resolved unless --no-resolve is specified\n")
 	usage.WriteString("The password can also be set via the environment variable 'KSPASSWORD'\n")
+	usage.WriteString("The token can also be set via the environment variable 'KSTOKEN'\n")
 
 	return usage.String()
 }
@@ -148,6 +332,26 @@ func (options *Options) verifyCommon(stderr io.Writer) bool {
 	return true
 }
 
+// check layered databases: a password (and optional key file) for each database and only commands reading the database
+func (options *Options) verifyLayers(stderr io.Writer) bool {
+	if len(options.pws) != 1 && len(options.pws) != len(options.dbs) {
+		fmt.Fprintf(stderr, "number of -p/--password parameters must be 1 or match the number of -d/--database parameters\n")
+		return false
+	}
+
+	if len(options.keyFiles) > 1 && len(options.keyFiles) != len(options.dbs) {
+		fmt.Fprintf(stderr, "number of -k/--key-file parameters must be 0, 1 or match the number of -d/--database parameters\n")
+		return false
+	}
+
+	if len(options.dbs) > 1 && (options.cmd == "init" || options.cmd == "set" || options.cmd == "import" || options.cmd == "serve") {
+		fmt.Fprintf(stderr, "multiple -d/--database parameters are not supported by %s command\n", options.cmd)
+		return false
+	}
+
+	return true
+}
+
 // check presence of mandatory options for export and secrets command
 func (options *Options) verifyExportOrSecrets(stderr io.Writer) bool {
 	if options.out == "" {
@@ -158,6 +362,35 @@ func (options *Options) verifyExportOrSecrets(stderr io.Writer) bool {
 	return true
 }
 
+// check output format of secrets, certs and lint command
+func (options *Options) verifyFormat(stderr io.Writer) bool {
+	formats := []string{"", "manifest", "kustomize", "helm"}
+	if options.cmd == "certs" {
+		formats = []string{"", "text", "json"}
+	} else if options.cmd == "lint" {
+		formats = []string{"", "text", "json", "sarif"}
+	}
+
+	if !contains(formats, options.format) {
+		fmt.Fprintf(stderr, "unknown format %s\n", options.format)
+		return false
+	}
+
+	return true
+}
+
+// parse tag filter expressions
+func (options *Options) verifyTags(stderr io.Writer) bool {
+	filter, err := NewTagFilter(options.tags, options.tagSource)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return false
+	}
+
+	options.tagFilter = filter
+	return true
+}
+
 // check presence of mandatory options for get command
 func (options *Options) verifyGet(stderr io.Writer) bool {
 	if options.path == "" {
@@ -165,6 +398,14 @@ func (options *Options) verifyGet(stderr io.Writer) bool {
 		return false
 	}
 
+	if options.as != "" {
+		if _, ok := keystoreKeys[options.as]; !ok {
+			fmt.Fprintf(stderr, "unknown keystore format %s\n", options.as)
+			return false
+		}
+		return true // keystore conversion does not require a field
+	}
+
 	if len(options.fields) != 1 {
 		fmt.Fprintf(stderr, "missing -f/--field parameter\n")
 		return false
@@ -198,13 +439,59 @@ func (options *Options) verifyImport(stderr io.Writer) bool {
 	return true
 }
 
+// check presence of mandatory options for serve command
+func (options *Options) verifyServe(stderr io.Writer) bool {
+	if options.token == "" {
+		fmt.Fprintf(stderr, "missing --token parameter\n")
+		return false
+	}
+
+	if (options.tlsCert == "") != (options.tlsKey == "") {
+		fmt.Fprintf(stderr, "--tls-cert and --tls-key must be specified together\n")
+		return false
+	}
+
+	return true
+}
+
+// check mode of --dry-run (server is only supported by apply command)
+func (options *Options) verifyDryRun(stderr io.Writer) bool {
+	modes := []string{"", "client"}
+	if options.cmd == "apply" {
+		modes = []string{"", "client", "server"}
+	}
+
+	if !contains(modes, options.dryRun) {
+		fmt.Fprintf(stderr, "unknown dry-run mode %s\n", options.dryRun)
+		return false
+	}
+
+	return true
+}
+
 // check plausibility of commandline options
 func (options *Options) verify(stderr io.Writer) bool {
 	if !options.verifyCommon(stderr) {
 		return false
 	}
 
-	if options.cmd == "secrets" && !options.verifyExportOrSecrets(stderr) {
+	if !options.verifyLayers(stderr) {
+		return false
+	}
+
+	if options.cmd == "secrets" && options.diffAgainst == "" && !options.verifyExportOrSecrets(stderr) {
+		return false
+	}
+
+	if (options.cmd == "secrets" || options.cmd == "certs" || options.cmd == "lint") && !options.verifyFormat(stderr) {
+		return false
+	}
+
+	if !options.verifyDryRun(stderr) {
+		return false
+	}
+
+	if !options.verifyTags(stderr) {
 		return false
 	}
 
@@ -224,6 +511,10 @@ func (options *Options) verify(stderr io.Writer) bool {
 		return false
 	}
 
+	if options.cmd == "serve" && !options.verifyServe(stderr) {
+		return false
+	}
+
 	return true
 }
 
@@ -239,6 +530,45 @@ func (options *Options) GetPw() string {
 	return options.pw
 }
 
+// returns all database files (layers)
+func (options *Options) GetDbs() []string {
+	return options.dbs
+}
+
+// returns the password of each database file
+func (options *Options) GetPws() []string {
+	if len(options.pws) == 1 {
+		pws := make([]string, len(options.dbs))
+		for i := 0; i < len(pws); i++ {
+			pws[i] = options.pw
+		}
+		return pws
+	}
+
+	return options.pws
+}
+
+func (options *Options) GetKeyFile() string {
+	return options.keyFile
+}
+
+// returns the key file of each database file (empty for none)
+func (options *Options) GetKeyFiles() []string {
+	if len(options.keyFiles) <= 1 {
+		keyFiles := make([]string, len(options.dbs))
+		for i := 0; i < len(keyFiles); i++ {
+			keyFiles[i] = options.keyFile
+		}
+		return keyFiles
+	}
+
+	return options.keyFiles
+}
+
+func (options *Options) IsLayers() bool {
+	return options.layers
+}
+
 func (options *Options) GetPath() string {
 	return options.path
 }
@@ -252,6 +582,10 @@ func (options *Options) GetIn() string {
 }
 
 func (options *Options) IsDryRun() bool {
+	return options.dryRun != ""
+}
+
+func (options *Options) GetDryRun() string {
 	return options.dryRun
 }
 
@@ -259,10 +593,94 @@ func (options *Options) IsQuiet() bool {
 	return options.quiet
 }
 
-func (options *Options) GetTag() string {
-	return options.tag
+func (options *Options) GetTags() []string {
+	return options.tags
+}
+
+func (options *Options) GetTagSource() string {
+	return options.tagSource
+}
+
+func (options *Options) GetTagFilter() *TagFilter {
+	return options.tagFilter
 }
 
 func (options *Options) GetFields() []string {
 	return options.fields
 }
+
+func (options *Options) GetListen() string {
+	return options.listen
+}
+
+func (options *Options) GetToken() string {
+	return options.token
+}
+
+func (options *Options) GetTlsCert() string {
+	return options.tlsCert
+}
+
+func (options *Options) GetTlsKey() string {
+	return options.tlsKey
+}
+
+func (options *Options) GetFormat() string {
+	return options.format
+}
+
+func (options *Options) GetNameTemplate() string {
+	return options.nameTemplate
+}
+
+func (options *Options) GetWarnDays() int {
+	return options.warnDays
+}
+
+func (options *Options) IsAll() bool {
+	return options.all
+}
+
+func (options *Options) IsStrict() bool {
+	return options.strict
+}
+
+func (options *Options) IsStringData() bool {
+	return options.stringData
+}
+
+func (options *Options) IsNoResolve() bool {
+	return options.noResolve
+}
+
+func (options *Options) GetAs() string {
+	return options.as
+}
+
+func (options *Options) GetKubeconfig() string {
+	return options.kubeconfig
+}
+
+func (options *Options) GetContext() string {
+	return options.context
+}
+
+func (options *Options) GetNamespace() string {
+	return options.namespace
+}
+
+func (options *Options) IsCreateNamespace() bool {
+	return options.createNs
+}
+
+func (options *Options) IsPrune() bool {
+	return options.prune
+}
+
+func (options *Options) IsForce() bool {
+	return options.force
+}
+
+func (options *Options) GetDiffAgainst() string {
+	return options.diffAgainst
+}
@@ -271,3 +271,113 @@ func TestFieldType(t *testing.T) {
 		t.Errorf("invalid type %s", options.fields.Type())
 	}
 }
+
+// missing --token option
+func TestOptionsServeNoToken(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	args := []string{"serve", "-d", "test.kdbx", "-p", "1234"}
+	result := Run(args, &stdout, &stderr)
+
+	if result == 0 {
+		t.Errorf("run must fail")
+		return
+	}
+
+	expected := "missing --token parameter\n"
+	actual := stderr.String()
+	if expected != actual {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+		return
+	}
+}
+
+// --tls-cert without --tls-key option
+func TestOptionsServeTlsCertOnly(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	args := []string{"serve", "-d", "test.kdbx", "-p", "1234", "--token", "abc", "--tls-cert", "crt.pem"}
+	result := Run(args, &stdout, &stderr)
+
+	if result == 0 {
+		t.Errorf("run must fail")
+		return
+	}
+
+	expected := "--tls-cert and --tls-key must be specified together\n"
+	actual := stderr.String()
+	if expected != actual {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+		return
+	}
+}
+
+// invalid --format option
+func TestOptionsSecretsInvalidFormat(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	args := []string{"secrets", "-d", "test.kdbx", "-p", "1234", "-o", "out.yaml", "--format", "xml"}
+	result := Run(args, &stdout, &stderr)
+
+	if result == 0 {
+		t.Errorf("run must fail")
+		return
+	}
+
+	expected := "unknown format xml\n"
+	actual := stderr.String()
+	if expected != actual {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+		return
+	}
+}
+
+// invalid --tag expression
+func TestOptionsInvalidTag(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	args := []string{"list", "-d", "test.kdbx", "-p", "1234", "-t", "prod && (dev"}
+	result := Run(args, &stdout, &stderr)
+
+	if result == 0 {
+		t.Errorf("run must fail")
+		return
+	}
+
+	expected := "invalid tag filter 'prod && (dev': missing ')'\n"
+	actual := stderr.String()
+	if expected != actual {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+		return
+	}
+}
+
+// boolean values of --dry-run are aliases of client mode and no dry run
+func TestOptionsDryRunBool(t *testing.T) {
+	tests := []struct {
+		arg      string
+		expected string
+	}{
+		{"--dry-run", "client"},
+		{"--dry-run=true", "client"},
+		{"--dry-run=false", ""},
+		{"--dry-run=client", "client"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		stderr := strings.Builder{}
+		options := NewOptions()
+		if !options.Parse([]string{"set", "-d", "test.kdbx", "-p", "1234", "-e", "/a", "-f", "A=1", tests[i].arg}, &stderr) {
+			t.Errorf("%s: parse failed %s", tests[i].arg, stderr.String())
+			continue
+		}
+
+		if options.GetDryRun() != tests[i].expected {
+			t.Errorf("%s: expected %s, actual %s", tests[i].arg, tests[i].expected, options.GetDryRun())
+		}
+	}
+}
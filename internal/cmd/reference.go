@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// KeePass field references and placeholders
+// {REF:<wanted field>@<search field>:<text>} e.g. {REF:P@I:46C9B1FFBD4ABC4BBB260C6190BAD20C}
+// {TITLE}, {USERNAME}, {PASSWORD}, {URL}, {NOTES} and {S:<custom field>} of the same entry
+var referencePattern = regexp.MustCompile(`(?i)\{(REF:[TUPANI]@[TUPANIO]:[^}]*|TITLE|USERNAME|PASSWORD|URL|NOTES|S:[^}]+)\}`)
+
+// standard fields of the reference codes
+var referenceFields = map[string]string{
+	"T": "Title",
+	"U": "UserName",
+	"P": "Password",
+	"A": "URL",
+	"N": "Notes",
+}
+
+// resolves references recursively based on the raw values of the entry map
+type referenceResolver struct {
+	entryMap *EntryMap
+	stack    map[string]bool // fields currently being resolved (cycle detection)
+	reported map[string]bool // reported warnings
+	stderr   io.Writer
+}
+
+// replace references and placeholders in all fields by the referenced values
+// unresolvable references and cycles are reported and kept as they are
+func (entryMap *EntryMap) ResolveReferences(stderr io.Writer) {
+	resolver := &referenceResolver{entryMap: entryMap, stack: make(map[string]bool), reported: make(map[string]bool), stderr: stderr}
+	resolved := make(map[string]Entry)
+	for i := 0; i < len(entryMap.paths); i++ {
+		path := entryMap.paths[i]
+		values := entryMap.entries[path]
+		entry := NewEntry()
+		names := values.GetNames()
+		for j := 0; j < len(names); j++ {
+			entry.SetValue(names[j], resolver.resolveField(path, names[j]))
+		}
+
+		entry.binaries = values.binaries
+		entry.tags = values.tags
+		resolved[path] = *entry
+	}
+
+	entryMap.entries = resolved
+	entryMap.warnings += len(resolver.reported)
+}
+
+// returns the resolved value of a field
+func (resolver *referenceResolver) resolveField(path string, field string) string {
+	values := resolver.entryMap.entries[path]
+	value, _ := values.GetValue(field)
+	if !strings.Contains(value, "{") {
+		return value // fast path: no reference
+	}
+
+	key := path + "\x00" + field
+	if resolver.stack[key] {
+		resolver.warn("field '%s' of entry '%s' contains a reference cycle", field, path)
+		return value
+	}
+
+	resolver.stack[key] = true
+	defer delete(resolver.stack, key)
+
+	result := strings.Builder{}
+	last := 0
+	matches := referencePattern.FindAllStringIndex(value, -1)
+	for i := 0; i < len(matches); i++ {
+		start, end := matches[i][0], matches[i][1]
+		if (start > 0 && value[start-1] == '{') || (end < len(value) && value[end] == '}') {
+			continue // part of template action e.g. tmpl:{{UserName}}
+		}
+
+		token := value[start:end]
+		resolved, ok := resolver.resolveToken(path, token)
+		if !ok {
+			resolver.warn("field '%s' of entry '%s' contains unresolved reference %s", field, path, token)
+			resolved = token
+		}
+
+		result.WriteString(value[last:start])
+		result.WriteString(resolved)
+		last = end
+	}
+	result.WriteString(value[last:])
+
+	return result.String()
+}
+
+// resolve a single reference or placeholder of the entry at path
+func (resolver *referenceResolver) resolveToken(path string, token string) (string, bool) {
+	name := token[1 : len(token)-1]
+	upper := strings.ToUpper(name)
+
+	switch {
+	case strings.HasPrefix(upper, "S:"):
+		return resolver.resolveExisting(path, name[2:])
+	case strings.HasPrefix(upper, "REF:"):
+		wanted := upper[4:5]
+		search := upper[6:7]
+		text := name[8:]
+		target, ok := resolver.find(search, text)
+		if !ok {
+			return "", false
+		}
+
+		if wanted == "I" {
+			return resolver.entryMap.uuids[target], true
+		}
+		return resolver.resolveExisting(target, referenceFields[wanted])
+	}
+
+	for _, field := range referenceFields {
+		if strings.EqualFold(field, upper) {
+			return resolver.resolveExisting(path, field)
+		}
+	}
+
+	return "", false
+}
+
+// returns the resolved value of the field if it exists
+func (resolver *referenceResolver) resolveExisting(path string, field string) (string, bool) {
+	values := resolver.entryMap.entries[path]
+	if _, ok := values.GetValue(field); !ok {
+		return "", false
+	}
+
+	return resolver.resolveField(path, field), true
+}
+
+// find first entry whose search field equals text (case-insensitive)
+// search field is one of the reference codes, O searches all custom fields
+func (resolver *referenceResolver) find(search string, text string) (string, bool) {
+	paths := resolver.entryMap.paths
+	for i := 0; i < len(paths); i++ {
+		path := paths[i]
+		if search == "I" {
+			if strings.EqualFold(resolver.entryMap.uuids[path], text) {
+				return path, true
+			}
+			continue
+		}
+
+		values := resolver.entryMap.entries[path]
+		names := []string{referenceFields[search]}
+		if search == "O" {
+			names = values.GetNames()
+		}
+
+		for j := 0; j < len(names); j++ {
+			if _, standard := standardFields[names[j]]; search == "O" && standard {
+				continue
+			}
+
+			if value, ok := values.GetValue(names[j]); ok && strings.EqualFold(value, text) {
+				return path, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// print each warning only once
+func (resolver *referenceResolver) warn(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if !resolver.reported[message] {
+		resolver.reported[message] = true
+		fmt.Fprintf(resolver.stderr, "%s\n", message)
+	}
+}
+
+// standard fields of KeePass entries (not searched by {REF:...@O:...})
+var standardFields = map[string]bool{
+	"Title":    true,
+	"UserName": true,
+	"Password": true,
+	"URL":      true,
+	"Notes":    true,
+}
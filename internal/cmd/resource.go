@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// models a generated Kubernetes Secret or ConfigMap
+// the data keys are kept in the order of their definition
+type Resource struct {
+	path       string // path of the KeePass entry
+	kind       string // Secret or ConfigMap
+	name       string
+	namespace  string
+	secretType string // e.g. Opaque, empty for ConfigMap
+	keys       []string
+	data       map[string][]byte
+	stringData bool // write text values of a secret as stringData
+	immutable  bool
+}
+
+func NewResource(kind string, name string, namespace string, secretType string) *Resource {
+	return &Resource{kind: kind, name: name, namespace: namespace, secretType: secretType, keys: make([]string, 0), data: make(map[string][]byte)}
+}
+
+// add or replace data value
+func (resource *Resource) SetData(key string, value []byte) {
+	if _, ok := resource.data[key]; !ok {
+		resource.keys = append(resource.keys, key)
+	}
+	resource.data[key] = value
+}
+
+func (resource *Resource) GetData(key string) ([]byte, bool) {
+	value, ok := resource.data[key]
+	return value, ok
+}
+
+func (resource *Resource) GetKeys() []string {
+	return resource.keys
+}
+
+func (resource *Resource) IsSecret() bool {
+	return resource.kind == "Secret"
+}
+
+// returns short hash of kind, type and data (used as name suffix)
+func (resource *Resource) Hash() string {
+	keys := make([]string, len(resource.keys))
+	copy(keys, resource.keys)
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	hash.Write([]byte(resource.kind + "\x00" + resource.secretType + "\x00"))
+	for i := 0; i < len(keys); i++ {
+		hash.Write([]byte(keys[i] + "\x00"))
+		hash.Write(resource.data[keys[i]])
+		hash.Write([]byte("\x00"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:10]
+}
+
+// render resource as YAML document
+// secret values are base64 encoded, ConfigMap values are quoted plain text
+func (resource *Resource) appendManifest(lines *[]string) {
+	if len(*lines) > 0 {
+		*lines = append(*lines, "")
+		*lines = append(*lines, "---")
+	}
+
+	*lines = append(*lines, "apiVersion: v1")
+	*lines = append(*lines, "kind: "+resource.kind)
+	*lines = append(*lines, "metadata:")
+	*lines = append(*lines, "  name: \""+resource.name+"\"")
+	if len(resource.namespace) > 0 {
+		*lines = append(*lines, "  namespace: \""+resource.namespace+"\"")
+	}
+	if resource.IsSecret() {
+		*lines = append(*lines, "type: "+resource.secretType)
+	}
+	if resource.immutable {
+		*lines = append(*lines, "immutable: true")
+	}
+
+	// with stringData only binary values remain in data
+	dataKeys := make([]string, 0)
+	stringKeys := make([]string, 0)
+	for i := 0; i < len(resource.keys); i++ {
+		key := resource.keys[i]
+		if resource.stringData && resource.IsSecret() && isText(resource.data[key]) {
+			stringKeys = append(stringKeys, key)
+		} else {
+			dataKeys = append(dataKeys, key)
+		}
+	}
+
+	if len(dataKeys) > 0 || len(stringKeys) == 0 {
+		*lines = append(*lines, "data:")
+		for i := 0; i < len(dataKeys); i++ {
+			key := dataKeys[i]
+			*lines = append(*lines, "  "+key+": "+resource.encode(resource.data[key]))
+		}
+	}
+
+	if len(stringKeys) > 0 {
+		*lines = append(*lines, "stringData:")
+		for i := 0; i < len(stringKeys); i++ {
+			appendYamlString(lines, "  ", stringKeys[i], string(resource.data[stringKeys[i]]))
+		}
+	}
+}
+
+// check if value can be written as YAML text (valid UTF-8 without control characters except tab and line feed)
+func isText(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+
+	for _, r := range string(value) {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// append key and plain text value, multi-line values are written as literal block scalar
+// values with several trailing line breaks are quoted, with keep chomping ("|+") the empty
+// line before the next document separator would become part of the value
+func appendYamlString(lines *[]string, indent string, key string, value string) {
+	content := strings.TrimRight(value, "\n")
+	trailing := len(value) - len(content)
+	if !strings.Contains(value, "\n") || trailing > 1 || content == "" {
+		*lines = append(*lines, indent+key+": "+quote(value))
+		return
+	}
+
+	// chomping indicator keeps the trailing line break
+	header := "|-"
+	if trailing == 1 {
+		header = "|"
+	}
+
+	// explicit indentation if the first line starts with a space
+	if strings.HasPrefix(strings.TrimLeft(content, "\n"), " ") {
+		header = header[:1] + "2" + header[1:]
+	}
+
+	*lines = append(*lines, indent+key+": "+header)
+	blockLines := strings.Split(content, "\n")
+
+	for i := 0; i < len(blockLines); i++ {
+		if blockLines[i] == "" {
+			*lines = append(*lines, "")
+		} else {
+			*lines = append(*lines, indent+"  "+blockLines[i])
+		}
+	}
+}
+
+// encode value as quoted YAML string (base64 for secrets)
+func (resource *Resource) encode(value []byte) string {
+	if resource.IsSecret() {
+		return "\"" + base64.StdEncoding.EncodeToString(value) + "\""
+	}
+
+	return quote(string(value))
+}
+
+// render all resources as multi-document YAML
+func renderManifests(resources []Resource) []string {
+	lines := make([]string, 0)
+	for i := 0; i < len(resources); i++ {
+		resources[i].appendManifest(&lines)
+	}
+
+	return lines
+}
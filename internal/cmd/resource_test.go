@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// plain text values as quoted strings or literal block scalars
+func TestResourceYamlString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"admin", "  key: \"admin\""},
+		{"", "  key: \"\""},
+		{"line 1\nline 2", "  key: |-\n    line 1\n    line 2"},
+		{"line 1\nline 2\n", "  key: |\n    line 1\n    line 2"},
+		{"line 1\n\nline 3\n\n", "  key: \"line 1\\n\\nline 3\\n\\n\""},
+		{"\n", "  key: \"\\n\""},
+		{"  indented\nline 2\n", "  key: |2\n      indented\n    line 2"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		lines := make([]string, 0)
+		appendYamlString(&lines, "  ", "key", tests[i].value)
+		if actual := strings.Join(lines, "\n"); actual != tests[i].expected {
+			t.Errorf("test %d: expected: %s", i, tests[i].expected)
+			t.Errorf("test %d: actual:   %s", i, actual)
+		}
+	}
+}
+
+// values followed by a document separator keep their exact trailing line breaks
+func TestResourceYamlStringRoundTrip(t *testing.T) {
+	values := []string{"x\n\n", "x\n", "x", "\n", "a\n\nb\n\n\n"}
+	for i := 0; i < len(values); i++ {
+		lines := []string{"stringData:"}
+		appendYamlString(&lines, "  ", "key", values[i])
+		lines = append(lines, "", "---", "kind: Secret")
+
+		decoder := yaml.NewDecoder(strings.NewReader(strings.Join(lines, "\n")))
+		document := struct {
+			StringData map[string]string `yaml:"stringData"`
+		}{}
+		if err := decoder.Decode(&document); err != nil || document.StringData["key"] != values[i] {
+			t.Errorf("expected: %q", values[i])
+			t.Errorf("actual:   %q %v", document.StringData["key"], err)
+		}
+	}
+}
+
+// stringData for text values, data for binary values, per entry annotation overrides option
+func TestResourceStringData(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/app", "Title": "app", "UserName": "admin", "Config": "a: 1\nb: 2\n", "Key": "AAEC/w==",
+			"Notes": "secret-type=opaque\nsecret-user=UserName\nsecret-config.yaml=Config\nsecret-key.bin=base64:Key"},
+		{"path": "/db", "Title": "db", "UserName": "admin", "Notes": "secret-type=opaque\nsecret-user=UserName\nsecret-string-data=false"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources, _ := collectResources(entryMap, nil, "", true, &stdout, &stderr)
+
+	expected := strings.Join([]string{
+		"apiVersion: v1",
+		"kind: Secret",
+		"metadata:",
+		"  name: \"app\"",
+		"type: Opaque",
+		"data:",
+		"  key.bin: \"AAEC/w==\"",
+		"stringData:",
+		"  user: \"admin\"",
+		"  config.yaml: |",
+		"    a: 1",
+		"    b: 2",
+		"",
+		"---",
+		"apiVersion: v1",
+		"kind: Secret",
+		"metadata:",
+		"  name: \"db\"",
+		"type: Opaque",
+		"data:",
+		"  user: \"YWRtaW4=\"",
+	}, "\n")
+
+	if actual := strings.Join(renderManifests(resources), "\n"); actual != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", actual)
+	}
+}
+
+// immutable resources with content hash in name
+func TestResourceImmutableNameHash(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/app", "Title": "app", "UserName": "admin",
+			"Notes": "secret-type=opaque\nsecret-user=UserName\nsecret-namespace=dev,prod\nsecret-immutable=true\nsecret-name-hash=true"},
+	})
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	resources, _ := collectResources(entryMap, nil, "", false, &stdout, &stderr)
+	if len(resources) != 2 {
+		t.Errorf("unexpected resources %d %s", len(resources), stderr.String())
+		return
+	}
+
+	hash := resources[0].Hash()
+	if len(hash) != 10 || resources[0].name != "app-"+hash || resources[1].name != "app-"+hash || !resources[1].immutable {
+		t.Errorf("unexpected resources %s %s %s", hash, resources[0].name, resources[1].name)
+	}
+
+	expected := "secret opaque name=app fields=user\nsecret opaque name=app fields=user\nsecret name=app-" + hash + " hash=" + hash + "\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+
+	lines := renderManifests(resources[:1])
+	if lines[5] != "type: Opaque" || lines[6] != "immutable: true" {
+		t.Errorf("unexpected manifest %v", lines)
+	}
+
+	// hash changes with data
+	other := *NewResource("Secret", "app", "", "Opaque")
+	other.SetData("user", []byte("root"))
+	if other.Hash() == hash {
+		t.Errorf("hash must depend on data")
+	}
+}
@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// boolean tag expression e.g. "prod && !legacy", "taga,tagb" or "team-*"
+// operators by precedence: ! (not), && (and), || or , (or), parentheses group
+// operands are glob patterns (see path.Match) matched against each tag of the entry
+type tagExpr interface {
+	match(tags []string) bool
+}
+
+type tagPattern struct {
+	pattern string
+}
+
+type tagNot struct {
+	expr tagExpr
+}
+
+type tagAnd struct {
+	left  tagExpr
+	right tagExpr
+}
+
+type tagOr struct {
+	left  tagExpr
+	right tagExpr
+}
+
+func (expr tagPattern) match(tags []string) bool {
+	for i := 0; i < len(tags); i++ {
+		if ok, _ := path.Match(expr.pattern, tags[i]); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (expr tagNot) match(tags []string) bool {
+	return !expr.expr.match(tags)
+}
+
+func (expr tagAnd) match(tags []string) bool {
+	return expr.left.match(tags) && expr.right.match(tags)
+}
+
+func (expr tagOr) match(tags []string) bool {
+	return expr.left.match(tags) || expr.right.match(tags)
+}
+
+// sources of entry tags
+var tagSources = []string{
+	"notes",  // "secret-tags=" line of notes
+	"native", // KeePass tags field
+	"both",   // union of notes and native tags (default)
+}
+
+// filter of entries by tags
+// multiple expressions (several --tag options) are combined with or
+type TagFilter struct {
+	exprs  []tagExpr
+	source string // one of tagSources
+	scope  string // label value identifying the filter (see Scope)
+}
+
+// parse all tag expressions, empty expressions are ignored
+func NewTagFilter(filters []string, source string) (*TagFilter, error) {
+	if !contains(tagSources, source) {
+		return nil, fmt.Errorf("unknown tag source %s", source)
+	}
+
+	filter := &TagFilter{exprs: make([]tagExpr, 0), source: source}
+	hash := sha256.New()
+	hash.Write([]byte(source))
+	for i := 0; i < len(filters); i++ {
+		if strings.TrimSpace(filters[i]) == "" {
+			continue
+		}
+
+		expr, err := parseTagExpr(filters[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag filter '%s': %s", filters[i], err)
+		}
+		filter.exprs = append(filter.exprs, expr)
+		hash.Write([]byte("\n" + strings.TrimSpace(filters[i])))
+	}
+
+	if len(filter.exprs) > 0 {
+		filter.scope = fmt.Sprintf("tags-%x", hash.Sum(nil)[:5])
+	}
+
+	return filter, nil
+}
+
+// label value identifying the resources selected by the filter
+// "all" without filter, otherwise a hash of the tag expressions and the tag source
+func (filter *TagFilter) Scope() string {
+	if filter == nil || filter.scope == "" {
+		return "all"
+	}
+
+	return filter.scope
+}
+
+// check if entry with tags should be included
+// an empty filter includes all entries
+func (filter *TagFilter) Match(tags []string) bool {
+	if filter == nil || len(filter.exprs) == 0 {
+		return true
+	}
+
+	for i := 0; i < len(filter.exprs); i++ {
+		if filter.exprs[i].match(tags) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns the tags of an entry according to the tag source of the filter
+// without filter the tags of notes and the native tags are used
+func (filter *TagFilter) EntryTags(notes *Notes, values Entry) []string {
+	source := "both"
+	if filter != nil {
+		source = filter.source
+	}
+
+	tags := make([]string, 0)
+	if source != "native" {
+		tags = append(tags, splitTags(notes.Get("tags"))...)
+	}
+
+	if source != "notes" {
+		native := values.GetTags()
+		for i := 0; i < len(native); i++ {
+			if !contains(tags, native[i]) {
+				tags = append(tags, native[i])
+			}
+		}
+	}
+
+	return tags
+}
+
+// split list of tags separated by comma or semicolon (KeePass native format)
+func splitTags(text string) []string {
+	tags := make([]string, 0)
+	list := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ';' })
+	for i := 0; i < len(list); i++ {
+		if tag := strings.TrimSpace(list[i]); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// recursive descent parser of tag expressions
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseTagExpr(text string) (tagExpr, error) {
+	tokens, err := tokenizeTagExpr(text)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &tagParser{tokens: tokens}
+	expr, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected '%s'", parser.tokens[parser.pos])
+	}
+
+	return expr, nil
+}
+
+// split expression into operators and patterns
+func tokenizeTagExpr(text string) ([]string, error) {
+	tokens := make([]string, 0)
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '!' || c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(text) || text[i+1] != c {
+				return nil, fmt.Errorf("unexpected '%c', use '%c%c'", c, c, c)
+			}
+			tokens = append(tokens, text[i:i+2])
+			i += 2
+		default:
+			start := i
+			for i < len(text) && !strings.ContainsRune(" \t!(),&|", rune(text[i])) {
+				i++
+			}
+			pattern := text[start:i]
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s'", pattern)
+			}
+			tokens = append(tokens, pattern)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (parser *tagParser) peek() string {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos]
+	}
+
+	return ""
+}
+
+func (parser *tagParser) parseOr() (tagExpr, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek() == "||" || parser.peek() == "," {
+		parser.pos++
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = tagOr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (parser *tagParser) parseAnd() (tagExpr, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek() == "&&" {
+		parser.pos++
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = tagAnd{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (parser *tagParser) parseUnary() (tagExpr, error) {
+	token := parser.peek()
+	switch token {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "!":
+		parser.pos++
+		expr, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{expr: expr}, nil
+	case "(":
+		parser.pos++
+		expr, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if parser.peek() != ")" {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		parser.pos++
+		return expr, nil
+	case ")", ",", "&&", "||":
+		return nil, fmt.Errorf("unexpected '%s'", token)
+	}
+
+	parser.pos++
+	return tagPattern{pattern: token}, nil
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// boolean expressions and glob patterns
+func TestTagFilter(t *testing.T) {
+	tests := []struct {
+		filters  []string
+		tags     []string
+		expected bool
+	}{
+		{[]string{}, []string{}, true},
+		{[]string{"prod"}, []string{"prod"}, true},
+		{[]string{"prod"}, []string{"dev"}, false},
+		{[]string{"prod && !legacy"}, []string{"prod"}, true},
+		{[]string{"prod && !legacy"}, []string{"prod", "legacy"}, false},
+		{[]string{"taga,tagb"}, []string{"tagb"}, true},
+		{[]string{"taga || tagb"}, []string{"tagc"}, false},
+		{[]string{"cluster-*"}, []string{"cluster-east"}, true},
+		{[]string{"cluster-*"}, []string{"prod"}, false},
+		{[]string{"prod && (east || west)"}, []string{"prod", "west"}, true},
+		{[]string{"prod && (east || west)"}, []string{"dev", "west"}, false},
+		{[]string{"!prod"}, []string{}, true},
+		{[]string{"prod", "dev"}, []string{"dev"}, true},
+		{[]string{"prod", "dev"}, []string{"test"}, false},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		filter, err := NewTagFilter(tests[i].filters, "both")
+		if err != nil {
+			t.Errorf("test %d: unexpected error %s", i, err)
+			continue
+		}
+
+		if actual := filter.Match(tests[i].tags); actual != tests[i].expected {
+			t.Errorf("test %d: expected: %v", i, tests[i].expected)
+			t.Errorf("test %d: actual:   %v", i, actual)
+		}
+	}
+}
+
+// syntax errors
+func TestTagFilterInvalid(t *testing.T) {
+	tests := []struct {
+		filter   string
+		expected string
+	}{
+		{"prod &", "invalid tag filter 'prod &': unexpected '&', use '&&'"},
+		{"prod &&", "invalid tag filter 'prod &&': unexpected end of expression"},
+		{"(prod", "invalid tag filter '(prod': missing ')'"},
+		{"prod)", "invalid tag filter 'prod)': unexpected ')'"},
+		{"prod dev", "invalid tag filter 'prod dev': unexpected 'dev'"},
+		{"[prod", "invalid tag filter '[prod': invalid pattern '[prod'"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		_, err := NewTagFilter([]string{tests[i].filter}, "both")
+		if err == nil || err.Error() != tests[i].expected {
+			t.Errorf("expected: %s", tests[i].expected)
+			t.Errorf("actual:   %v", err)
+		}
+	}
+}
+
+// list entries matching tag filter
+func TestTagList(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/a", "Title": "a", "Notes": "secret-type=opaque\nsecret-tags=prod, east"},
+		{"path": "/b", "Title": "b", "Notes": "secret-type=opaque\nsecret-tags=prod,legacy"},
+		{"path": "/c", "Title": "c", "Notes": "secret-type=opaque\nsecret-tags=dev"},
+	})
+
+	filter, _ := NewTagFilter([]string{"prod && !legacy", "dev"}, "both")
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	CmdList(entryMap, filter, &stdout, &stderr)
+
+	expected := "/a tags=prod,east\n/c tags=dev\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+}
+
+// filter by tags of notes, native tags or both
+func TestTagSource(t *testing.T) {
+	entryMap := testNewEntryMap([]map[string]string{
+		{"path": "/a", "Title": "a", "Notes": "secret-type=opaque\nsecret-tags=prod"},
+		{"path": "/b", "Title": "b", "Tags": "prod;east"},
+		{"path": "/c", "Title": "c", "Notes": "secret-tags=dev", "Tags": "prod"},
+	})
+
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"notes", "/a tags=prod\n"},
+		{"native", "/b tags=prod,east\n/c tags=prod\n"},
+		{"both", "/a tags=prod\n/b tags=prod,east\n/c tags=dev,prod\n"},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		filter, _ := NewTagFilter([]string{"prod"}, tests[i].source)
+		stdout := strings.Builder{}
+		stderr := strings.Builder{}
+		CmdList(entryMap, filter, &stdout, &stderr)
+
+		if stdout.String() != tests[i].expected {
+			t.Errorf("expected: %s", tests[i].expected)
+			t.Errorf("actual:   %s", stdout.String())
+		}
+	}
+
+	if _, err := NewTagFilter([]string{}, "xml"); err == nil || err.Error() != "unknown tag source xml" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// set writes native tags
+func TestTagSet(t *testing.T) {
+	db := "test/tags.kdbx"
+	pw := "a1b2c3d4"
+
+	if !testCreateDatabase(db, pw, t) {
+		return
+	}
+
+	args := []string{"set", "-d", db, "-p", pw, "-e", "/1/A", "-f", "UserName=admin", "-f", "Tags=prod, east"}
+	if result := Run(args, &strings.Builder{}, &strings.Builder{}); result != 0 {
+		t.Errorf("set failed, result=%d", result)
+		return
+	}
+
+	args = []string{"list", "-d", db, "-p", pw, "-t", "east", "--tag-source", "native"}
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	if result := Run(args, &stdout, &stderr); result != 0 {
+		t.Errorf("list failed, result=%d %s", result, stderr.String())
+		return
+	}
+
+	expected := "/1/A tags=prod,east\n"
+	if stdout.String() != expected {
+		t.Errorf("expected: %s", expected)
+		t.Errorf("actual:   %s", stdout.String())
+	}
+
+	testDeleteFile(db, t)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/youmark/pkcs8"
+)
+
+const expiryWarnDays = 30 // warn if certificate expires within this number of days
+
+// parse all certificates of a PEM text (leaf certificate first, followed by the chain)
+func parseCertificates(text string) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0)
+	rest := []byte(text)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+
+	return certs, nil
+}
+
+// parse PEM private key (PKCS#1, PKCS#8 or EC)
+// encrypted keys (legacy PEM encryption or encrypted PKCS#8) are decrypted with the passphrase
+// returns the key and its unencrypted PEM text
+func parsePrivateKey(text string, passphrase string) (crypto.Signer, string, error) {
+	rest := []byte(text)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, "", errors.New("no PEM private key found")
+		}
+
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+
+		encrypted := false
+		der := block.Bytes
+
+		if x509.IsEncryptedPEMBlock(block) { //nolint:staticcheck // legacy PEM encryption is still common
+			if passphrase == "" {
+				return nil, "", errors.New("private key is encrypted, passphrase missing")
+			}
+
+			var err error
+			der, err = x509.DecryptPEMBlock(block, []byte(passphrase)) //nolint:staticcheck
+			if err != nil {
+				return nil, "", fmt.Errorf("cannot decrypt private key: %s", err)
+			}
+			encrypted = true
+		}
+
+		var key any
+		var err error
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(der)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(der)
+		case "ENCRYPTED PRIVATE KEY":
+			if passphrase == "" {
+				return nil, "", errors.New("private key is encrypted, passphrase missing")
+			}
+			key, err = pkcs8.ParsePKCS8PrivateKey(der, []byte(passphrase))
+			if err == nil {
+				der, err = x509.MarshalPKCS8PrivateKey(key)
+			}
+			block = &pem.Block{Type: "PRIVATE KEY"}
+			encrypted = true
+		default:
+			key, err = x509.ParsePKCS8PrivateKey(der)
+		}
+
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot parse private key: %s", err)
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, "", errors.New("unsupported private key type")
+		}
+
+		if !encrypted {
+			return signer, text, nil // keep original text
+		}
+
+		return signer, string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})), nil
+	}
+}
+
+// check if private key belongs to certificate
+func keyMatches(cert *x509.Certificate, key crypto.Signer) bool {
+	publicKey, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && publicKey.Equal(cert.PublicKey)
+}
+
+// returns remaining days until certificate expires (negative if expired)
+func daysRemaining(cert *x509.Certificate, now time.Time) int {
+	return int(cert.NotAfter.Sub(now).Hours() / 24)
+}
+
+// returns subject, SANs and expiry date of certificate
+func describeCertificate(cert *x509.Certificate) string {
+	return fmt.Sprintf("subject=%s sans=%s expires=%s", cert.Subject.String(), strings.Join(certificateSans(cert), ","), cert.NotAfter.UTC().Format(time.DateOnly))
+}
+
+// returns all DNS names, IP addresses, email addresses and URIs of certificate
+func certificateSans(cert *x509.Certificate) []string {
+	sans := make([]string, 0)
+	sans = append(sans, cert.DNSNames...)
+	for i := 0; i < len(cert.IPAddresses); i++ {
+		sans = append(sans, cert.IPAddresses[i].String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for i := 0; i < len(cert.URIs); i++ {
+		sans = append(sans, cert.URIs[i].String())
+	}
+
+	return sans
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -77,6 +79,8 @@ func createEntry(title string, fields []string, stdout io.Writer, stderr io.Writ
 					value = createPasswordFromPattern(pattern, stdout, stderr)
 				}
 				entry.Values = append(entry.Values, gokeepasslib.ValueData{Key: key, Value: gokeepasslib.V{Content: value, Protected: w.NewBoolWrapper(true)}})
+			} else if key == "Tags" {
+				entry.Tags = strings.Join(splitTags(value), ";") // native KeePass tags
 			} else if key == "Notes" {
 				value = strings.ReplaceAll(value, "\\n", "\n")
 				entry.Values = append(entry.Values, gokeepasslib.ValueData{Key: key, Value: gokeepasslib.V{Content: value}})
@@ -182,3 +186,60 @@ func writeFile(out string, pLines *[]string, stderr io.Writer) int {
 
 	return 0 // success
 }
+
+// open and decrypt KeePass database, protected entries are unlocked
+func openDatabase(file string, pw string, keyFile string) (*gokeepasslib.Database, error) {
+	readFile, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer readFile.Close()
+
+	db := gokeepasslib.NewDatabase()
+	db.Credentials, err = newCredentials(pw, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = gokeepasslib.NewDecoder(readFile).Decode(db)
+	if err != nil {
+		return nil, err
+	}
+
+	db.UnlockProtectedEntries()
+
+	if len(db.Content.Root.Groups) < 1 {
+		return nil, errors.New("missing root group")
+	}
+
+	return db, nil
+}
+
+// password credentials, combined with the key file if specified
+func newCredentials(pw string, keyFile string) (*gokeepasslib.DBCredentials, error) {
+	if keyFile == "" {
+		return gokeepasslib.NewPasswordCredentials(pw), nil
+	}
+
+	credentials, err := gokeepasslib.NewPasswordAndKeyCredentials(pw, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read key file %s", err)
+	}
+
+	return credentials, nil
+}
+
+// quote string as YAML double-quoted scalar
+// JSON strings are valid YAML, HTML characters are not escaped
+func quote(value string) string {
+	return string(marshalJson(value))
+}
+
+// encode as JSON without HTML escaping and trailing newline
+func marshalJson(value any) []byte {
+	str := strings.Builder{}
+	enc := json.NewEncoder(&str)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+	return []byte(strings.TrimSuffix(str.String(), "\n"))
+}
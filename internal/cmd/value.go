@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const tmplPrefix = "tmpl:"           // inline template e.g. tmpl:postgres://{{UserName}}@{{URL}}
+const tmplFieldPrefix = "tmplfield:" // template stored in a field e.g. tmplfield:ConfigTemplate
+const literalPrefix = "literal:"     // inline value e.g. literal:debug
+
+// modifiers applied to the value of the remaining mapping, can be combined e.g. trim:base64:KeyField
+var valueModifiers = []struct {
+	prefix string
+	name   string
+	apply  func(value []byte) ([]byte, error)
+}{
+	{"base64:", "base64", decodeBase64}, // value is already base64 encoded (e.g. binary key)
+	{"hex:", "hex", decodeHex},          // value is hex encoded
+	{"trim:", "trim", trimNewlines},     // remove trailing line breaks
+}
+
+// resolve the value of a secret key
+// the mapping is either a field name, a template (see tmplPrefix and tmplFieldPrefix) or a literal value
+// optionally prefixed by value modifiers (see valueModifiers)
+// the error message is meant to be prefixed with "entry '<path>' "
+func resolveValue(key string, mapping string, values Entry) ([]byte, error) {
+	if strings.HasPrefix(mapping, literalPrefix) {
+		return []byte(strings.TrimPrefix(mapping, literalPrefix)), nil
+	}
+
+	for i := 0; i < len(valueModifiers); i++ {
+		modifier := &valueModifiers[i]
+		if strings.HasPrefix(mapping, modifier.prefix) {
+			value, err := resolveValue(key, strings.TrimPrefix(mapping, modifier.prefix), values)
+			if err != nil {
+				return nil, err
+			}
+
+			result, err := modifier.apply(value)
+			if err != nil {
+				return nil, fmt.Errorf("contains invalid %s value for key '%s': %s", modifier.name, key, err)
+			}
+			return result, nil
+		}
+	}
+
+	if strings.HasPrefix(mapping, tmplPrefix) {
+		return renderValue(key, strings.TrimPrefix(mapping, tmplPrefix), values)
+	}
+
+	if strings.HasPrefix(mapping, tmplFieldPrefix) {
+		field := strings.TrimPrefix(mapping, tmplFieldPrefix)
+		text, ok := values.GetValue(field)
+		if !ok {
+			return nil, fmt.Errorf("does not contain the template field of key '%s'", key)
+		}
+		return renderValue(key, text, values)
+	}
+
+	value, ok := values.GetValue(mapping)
+	if !ok {
+		return nil, fmt.Errorf("does not contain the field of key '%s'", key) // the mapping may be a secret value
+	}
+
+	return []byte(value), nil
+}
+
+// builtin functions and keywords of text/template which must not be replaced by fields
+var templateBuiltins = map[string]bool{
+	"and": true, "call": true, "html": true, "index": true, "slice": true, "js": true, "len": true, "not": true,
+	"or": true, "print": true, "printf": true, "println": true, "urlquery": true,
+	"eq": true, "ge": true, "gt": true, "le": true, "lt": true, "ne": true,
+	"if": true, "else": true, "end": true, "range": true, "with": true, "define": true, "template": true,
+	"block": true, "break": true, "continue": true, "nil": true, "true": true, "false": true,
+}
+
+// render template with access to all fields of the entry
+// fields can be accessed as {{.UserName}}, {{UserName}} or {{field "User Name"}}
+// fields named like a builtin or helper function (e.g. "len", "json") only as {{.len}} or {{field "len"}}
+func renderValue(key string, text string, values Entry) ([]byte, error) {
+	funcs := template.FuncMap{}
+	funcs["field"] = func(name string) (string, error) {
+		value, ok := values.GetValue(name)
+		if !ok {
+			return "", fmt.Errorf("field '%s' does not exist", name)
+		}
+		return value, nil
+	}
+	funcs["urlescape"] = url.QueryEscape
+	funcs["pathescape"] = url.PathEscape
+	funcs["base64"] = func(str string) string { return base64.StdEncoding.EncodeToString([]byte(str)) }
+	funcs["json"] = func(str string) string { return quote(str) }
+	funcs["trim"] = strings.TrimSpace
+
+	data := make(map[string]string)
+	names := values.GetNames()
+	for i := 0; i < len(names); i++ {
+		name := names[i]
+		value, _ := values.GetValue(name)
+		data[name] = value
+		if _, exists := funcs[name]; isIdentifier(name) && !exists && !templateBuiltins[name] {
+			funcs[name] = func() string { return value }
+		}
+	}
+
+	tmpl, err := template.New(key).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("contains invalid template for key '%s': %s", key, err)
+	}
+
+	result := strings.Builder{}
+	if err := tmpl.Execute(&result, data); err != nil {
+		return nil, fmt.Errorf("cannot render template for key '%s': %s", key, err)
+	}
+
+	return []byte(result.String()), nil
+}
+
+// check if name can be used as template function name
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decode base64 value, line breaks and spaces are ignored
+func decodeBase64(value []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(value)), ""))
+}
+
+// decode hex value, line breaks and spaces are ignored
+func decodeHex(value []byte) ([]byte, error) {
+	return hex.DecodeString(strings.Join(strings.Fields(string(value)), ""))
+}
+
+func trimNewlines(value []byte) ([]byte, error) {
+	return []byte(strings.TrimRight(string(value), "\r\n")), nil
+}